@@ -0,0 +1,60 @@
+package jarchive
+
+import "time"
+
+// Kind describes what a resolved artifact is.
+type Kind string
+
+const (
+	KindServer    Kind = "server"    // runnable server jar
+	KindProxy     Kind = "proxy"     // runnable proxy jar, such as Velocity
+	KindLauncher  Kind = "launcher"  // server launcher that bootstraps a mod loader
+	KindInstaller Kind = "installer" // installer that produces the server files
+	KindClient    Kind = "client"    // game client jar
+	KindMappings  Kind = "mappings"  // obfuscation mappings
+	KindLibrary   Kind = "library"   // library the server loads at runtime
+)
+
+// HashAlgorithm names the algorithm an artifact checksum was computed with.
+type HashAlgorithm string
+
+const (
+	SHA1   HashAlgorithm = "sha1"
+	SHA256 HashAlgorithm = "sha256"
+	MD5    HashAlgorithm = "md5"
+)
+
+// Artifact describes a downloadable file resolved by a provider.
+//
+// Fields the upstream API does not report are left at their zero value.
+type Artifact struct {
+	URL      string `json:"url"`
+	FileName string `json:"file_name"`
+	Provider string `json:"provider"`
+	Kind     Kind   `json:"kind"`
+
+	Version string `json:"version"`         // Minecraft version, or proxy version for proxies
+	Build   string `json:"build,omitempty"` // build number, loader version or Forge version
+
+	Checksum          string        `json:"checksum,omitempty"`
+	ChecksumAlgorithm HashAlgorithm `json:"checksum_algorithm,omitempty"`
+	Size              int64         `json:"size,omitempty"`
+
+	ReleaseTime time.Time `json:"release_time"`
+
+	// Java is the Java runtime the artifact needs, or nil if the upstream API
+	// does not say.
+	Java *JavaRuntime `json:"java,omitempty"`
+}
+
+// JavaRuntime describes the Java runtime required to run an artifact.
+type JavaRuntime struct {
+	MajorVersion int `json:"major_version"` // minimum Java major version, e.g. 21
+
+	// Component is the runtime Mojang's launcher would install, e.g.
+	// "java-runtime-delta". Only the vanilla provider reports it.
+	Component string `json:"component,omitempty"`
+
+	// Flags are the JVM flags the upstream project recommends.
+	Flags []string `json:"flags,omitempty"`
+}
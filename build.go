@@ -0,0 +1,31 @@
+package jarchive
+
+import (
+	"context"
+	"time"
+)
+
+// Build is a single build of a server project for one Minecraft version.
+type Build struct {
+	ID      string    `json:"id"` // build number
+	Time    time.Time `json:"time"`
+	Channel string    `json:"channel,omitempty"` // release channel, e.g. "STABLE" or "ALPHA" on PaperMC
+	Result  string    `json:"result,omitempty"`  // CI result, e.g. "SUCCESS" or "FAILURE" on Purpur
+	Changes []Change  `json:"changes,omitempty"`
+
+	Checksum          string        `json:"checksum,omitempty"` // checksum of the build's server jar
+	ChecksumAlgorithm HashAlgorithm `json:"checksum_algorithm,omitempty"`
+}
+
+// Change is a commit that went into a build.
+type Change struct {
+	Commit  string `json:"commit"`
+	Summary string `json:"summary"`
+	Message string `json:"message"`
+}
+
+// BuildLister is implemented by providers that publish numbered builds per
+// Minecraft version. Builds are returned newest first.
+type BuildLister interface {
+	ListBuilds(ctx context.Context) ([]Build, error)
+}
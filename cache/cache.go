@@ -0,0 +1,26 @@
+// Package cache stores upstream API responses so repeated resolutions do not
+// have to download the same metadata again.
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// Entry is a cached response body together with the validators needed to
+// revalidate it with a conditional request.
+type Entry struct {
+	Body         []byte      `json:"body,omitempty"`
+	Header       http.Header `json:"header,omitempty"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	StoredAt     time.Time   `json:"stored_at"`
+}
+
+// Cache stores entries by key. Implementations must be safe for concurrent
+// use. Caching is best-effort: a failed write is dropped and a failed read
+// reports a miss.
+type Cache interface {
+	Get(key string) (*Entry, bool)
+	Set(key string, entry *Entry)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Dir is a Cache that stores one JSON file per entry in a directory, so
+// cached metadata survives process restarts.
+type Dir struct {
+	path string
+}
+
+// NewDir returns a cache rooted at path, creating the directory if needed.
+func NewDir(path string) (*Dir, error) {
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return nil, err
+	}
+	return &Dir{path: path}, nil
+}
+
+func (d *Dir) Get(key string) (*Entry, bool) {
+	data, err := os.ReadFile(d.file(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var stored struct {
+		Key string `json:"key"`
+		Entry
+	}
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Key != key {
+		return nil, false
+	}
+	return &stored.Entry, true
+}
+
+func (d *Dir) Set(key string, entry *Entry) {
+	data, err := json.Marshal(struct {
+		Key string `json:"key"`
+		*Entry
+	}{key, entry})
+	if err != nil {
+		return
+	}
+
+	// Write to a temporary file first so readers never see a partial entry.
+	f, err := os.CreateTemp(d.path, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), d.file(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+func (d *Dir) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.path, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDir_GetSet(t *testing.T) {
+	c, err := NewDir(filepath.Join(t.TempDir(), "cache"))
+	assert.NoError(t, err)
+
+	_, ok := c.Get("https://example.com/a")
+	assert.False(t, ok)
+
+	storedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Set("https://example.com/a", &Entry{
+		Body:         []byte(`{"versions":[]}`),
+		ETag:         `"v1"`,
+		LastModified: "Tue, 02 Jan 2024 03:04:05 GMT",
+		StoredAt:     storedAt,
+	})
+
+	entry, ok := c.Get("https://example.com/a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"versions":[]}`), entry.Body)
+	assert.Equal(t, `"v1"`, entry.ETag)
+	assert.Equal(t, "Tue, 02 Jan 2024 03:04:05 GMT", entry.LastModified)
+	assert.True(t, storedAt.Equal(entry.StoredAt))
+}
+
+func TestDir_SurvivesReopen(t *testing.T) {
+	path := t.TempDir()
+
+	c, err := NewDir(path)
+	assert.NoError(t, err)
+	c.Set("key", &Entry{Body: []byte("data")})
+
+	reopened, err := NewDir(path)
+	assert.NoError(t, err)
+	entry, ok := reopened.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("data"), entry.Body)
+}
+
+func TestDir_CorruptEntry(t *testing.T) {
+	c, err := NewDir(t.TempDir())
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(c.file("key"), []byte("not json"), 0o644))
+
+	_, ok := c.Get("key")
+	assert.False(t, ok)
+}
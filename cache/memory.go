@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"sync"
+)
+
+// Memory is a Cache that keeps entries in process memory.
+type Memory struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemory returns an empty in-memory cache.
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+func (m *Memory) Get(key string) (*Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (m *Memory) Set(key string, entry *Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = *entry
+}
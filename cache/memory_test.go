@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_GetSet(t *testing.T) {
+	c := NewMemory()
+
+	_, ok := c.Get("https://example.com/a")
+	assert.False(t, ok)
+
+	storedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	c.Set("https://example.com/a", &Entry{Body: []byte("a"), ETag: `"v1"`, StoredAt: storedAt})
+
+	entry, ok := c.Get("https://example.com/a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), entry.Body)
+	assert.Equal(t, `"v1"`, entry.ETag)
+	assert.Equal(t, storedAt, entry.StoredAt)
+}
+
+func TestMemory_GetReturnsCopy(t *testing.T) {
+	c := NewMemory()
+	c.Set("key", &Entry{ETag: `"v1"`})
+
+	entry, _ := c.Get("key")
+	entry.ETag = `"v2"`
+
+	entry, _ = c.Get("key")
+	assert.Equal(t, `"v1"`, entry.ETag)
+}
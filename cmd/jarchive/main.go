@@ -0,0 +1,379 @@
+// Command jarchive resolves and downloads Minecraft server jars.
+//
+// Usage:
+//
+//	jarchive url <provider> <version>
+//	jarchive download <provider> <version> [-o path]
+//	jarchive versions <provider>
+//	jarchive builds <provider> <version>
+//	jarchive providers
+//
+// Every command accepts --json to print machine-readable output. The exit
+// status tells callers what went wrong:
+//
+//	0  success
+//	1  unexpected error
+//	2  invalid usage
+//	3  unknown provider, version or build, or no server download
+//	4  upstream API or network failure, or not cached in offline mode
+//	5  downloaded file failed checksum verification
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	_ "github.com/ciathefed/jarchive/fabric"
+	_ "github.com/ciathefed/jarchive/forge"
+	"github.com/ciathefed/jarchive/internal/utils"
+	_ "github.com/ciathefed/jarchive/paper"
+	_ "github.com/ciathefed/jarchive/purpur"
+	_ "github.com/ciathefed/jarchive/vanilla"
+)
+
+const (
+	exitOK = iota
+	exitError
+	exitUsage
+	exitNotFound
+	exitUpstream
+	exitChecksum
+)
+
+const usage = `Usage:
+  jarchive url <provider> <version>                  print the download URL
+  jarchive download <provider> <version> [-o path]   download the server jar
+  jarchive versions <provider>                       list Minecraft versions
+  jarchive builds <provider> <version>               list builds of a version
+  jarchive providers                                 list providers
+
+Flags:
+  --json           print JSON instead of text
+  -o path          destination for download (default: upstream file name)
+  --retries n      resume an interrupted download up to n times (default 3)
+  --timeout d      give up after duration d, e.g. 30s (default: no limit)
+  --cache-dir p    cache upstream metadata in directory p
+  --cache-ttl d    reuse cached metadata for duration d before revalidating (default 10m)
+  --offline        resolve from --cache-dir only, without network access (not for download)
+  --user-agent s   send User-Agent s to upstream APIs and download servers
+`
+
+// errUsage marks errors caused by invalid command-line arguments.
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	stdout, stderr io.Writer
+
+	json      bool
+	output    string
+	retries   int
+	timeout   time.Duration
+	cacheDir  string
+	cacheTTL  time.Duration
+	offline   bool
+	userAgent string
+
+	opts []jarchive.Option
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	cmd := &command{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("jarchive", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&cmd.json, "json", false, "")
+	fs.StringVar(&cmd.output, "o", "", "")
+	fs.IntVar(&cmd.retries, "retries", 3, "")
+	fs.DurationVar(&cmd.timeout, "timeout", 0, "")
+	fs.StringVar(&cmd.cacheDir, "cache-dir", "", "")
+	fs.DurationVar(&cmd.cacheTTL, "cache-ttl", 10*time.Minute, "")
+	fs.BoolVar(&cmd.offline, "offline", false, "")
+	fs.StringVar(&cmd.userAgent, "user-agent", jarchive.DefaultUserAgent, "")
+
+	args, err := parseInterspersed(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "jarchive: %v\n\n%s", err, usage)
+		return exitUsage
+	}
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	if cmd.cacheDir != "" {
+		store, err := cache.NewDir(cmd.cacheDir)
+		if err != nil {
+			fmt.Fprintf(stderr, "jarchive: %v\n", err)
+			return exitError
+		}
+		cmd.opts = append(cmd.opts, jarchive.WithCache(store, cmd.cacheTTL))
+	}
+	if cmd.offline {
+		if cmd.cacheDir == "" {
+			fmt.Fprintf(stderr, "jarchive: --offline requires --cache-dir\n\n%s", usage)
+			return exitUsage
+		}
+		// Offline mode covers upstream metadata; a jar download always needs
+		// the network.
+		if args[0] == "download" {
+			fmt.Fprintf(stderr, "jarchive: --offline cannot be used with download\n\n%s", usage)
+			return exitUsage
+		}
+		cmd.opts = append(cmd.opts, jarchive.WithOffline(true))
+	}
+
+	cmd.opts = append(cmd.opts, jarchive.WithUserAgent(cmd.userAgent))
+
+	if cmd.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cmd.timeout)
+		defer cancel()
+	}
+
+	switch name, args := args[0], args[1:]; name {
+	case "url":
+		err = cmd.url(ctx, args)
+	case "download":
+		err = cmd.download(ctx, args)
+	case "versions":
+		err = cmd.versions(ctx, args)
+	case "builds":
+		err = cmd.builds(ctx, args)
+	case "providers":
+		err = cmd.providers(args)
+	case "help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	default:
+		err = fmt.Errorf("%w: unknown command %q", errUsage, name)
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jarchive: %v\n", err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "\n%s", usage)
+		}
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+func (c *command) url(ctx context.Context, args []string) error {
+	artifact, err := c.resolve(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(artifact)
+	}
+	fmt.Fprintln(c.stdout, artifact.URL)
+	return nil
+}
+
+func (c *command) download(ctx context.Context, args []string) error {
+	artifact, err := c.resolve(ctx, args)
+	if err != nil {
+		return err
+	}
+
+	dest := c.output
+	if dest == "" {
+		// Third-party providers may not sanitize the upstream name.
+		name, ok := utils.FileName(artifact.FileName)
+		if !ok {
+			return fmt.Errorf("%w: no usable file name for %s, pass -o", errUsage, artifact.URL)
+		}
+		dest = name
+	}
+
+	d := &jarchive.Downloader{MaxRetries: c.retries, UserAgent: c.userAgent}
+	if !c.json {
+		d.Progress = func(p jarchive.Progress) {
+			if p.Total > 0 {
+				fmt.Fprintf(c.stderr, "\r%s: %d/%d bytes (%.0f%%, %.1f KiB/s)", dest, p.Done, p.Total, float64(p.Done)*100/float64(p.Total), p.Rate/1024)
+			} else {
+				fmt.Fprintf(c.stderr, "\r%s: %d bytes (%.1f KiB/s)", dest, p.Done, p.Rate/1024)
+			}
+		}
+	}
+
+	err = d.Download(ctx, artifact, dest)
+	if !c.json {
+		fmt.Fprintln(c.stderr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(struct {
+			*jarchive.Artifact
+			Path string `json:"path"`
+		}{artifact, dest})
+	}
+	fmt.Fprintln(c.stdout, dest)
+	return nil
+}
+
+func (c *command) versions(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: versions takes exactly one provider", errUsage)
+	}
+
+	provider, err := jarchive.New(args[0], "", c.opts...)
+	if err != nil {
+		return err
+	}
+	lister, ok := provider.(jarchive.VersionLister)
+	if !ok {
+		return fmt.Errorf("%w: provider %s cannot list versions", errUsage, args[0])
+	}
+
+	versions, err := lister.ListVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(versions)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, v := range versions {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.ID, v.Type, formatTime(v.ReleaseTime))
+	}
+	return w.Flush()
+}
+
+func (c *command) builds(ctx context.Context, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: builds takes a provider and a version", errUsage)
+	}
+
+	provider, err := jarchive.New(args[0], args[1], c.opts...)
+	if err != nil {
+		return err
+	}
+	lister, ok := provider.(jarchive.BuildLister)
+	if !ok {
+		return fmt.Errorf("%w: provider %s does not publish builds", errUsage, args[0])
+	}
+
+	builds, err := lister.ListBuilds(ctx)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(builds)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	for _, b := range builds {
+		var summary string
+		if len(b.Changes) > 0 {
+			summary = b.Changes[0].Summary
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s%s\t%s\n", b.ID, formatTime(b.Time), b.Channel, b.Result, summary)
+	}
+	return w.Flush()
+}
+
+func (c *command) providers(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("%w: providers takes no arguments", errUsage)
+	}
+
+	if c.json {
+		return c.printJSON(jarchive.Providers())
+	}
+	for _, name := range jarchive.Providers() {
+		fmt.Fprintln(c.stdout, name)
+	}
+	return nil
+}
+
+func (c *command) printJSON(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *command) resolve(ctx context.Context, args []string) (*jarchive.Artifact, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("%w: expected a provider and a version", errUsage)
+	}
+
+	provider, err := jarchive.New(args[0], args[1], c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	return provider.ResolveContext(ctx)
+}
+
+// parseInterspersed parses flags that may appear before, between or after
+// the positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func exitCode(err error) int {
+	var upstreamErr *jarchive.UpstreamError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errUsage):
+		return exitUsage
+	case errors.Is(err, jarchive.ErrUnknownProvider),
+		errors.Is(err, jarchive.ErrVersionNotFound),
+		errors.Is(err, jarchive.ErrBuildNotFound),
+		errors.Is(err, jarchive.ErrNoServerArtifact):
+		return exitNotFound
+	case errors.Is(err, jarchive.ErrChecksumMismatch):
+		return exitChecksum
+	case errors.As(err, &upstreamErr), errors.As(err, &netErr),
+		errors.Is(err, jarchive.ErrOffline),
+		errors.Is(err, context.DeadlineExceeded):
+		return exitUpstream
+	default:
+		return exitError
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/stretchr/testify/assert"
+)
+
+var jarContent = []byte("not really a jar")
+
+// fakeServerURL is where the fake provider's artifacts are served from.
+var fakeServerURL string
+
+// fakeOptions holds the options the fake provider was last created with.
+var fakeOptions *jarchive.Options
+
+// fakeUserAgent is the User-Agent of the last request to fakeServerURL.
+var fakeUserAgent atomic.Value
+
+type fakeProvider struct {
+	version string
+}
+
+func (f *fakeProvider) Mirror() (string, error) {
+	return f.MirrorContext(context.Background())
+}
+
+func (f *fakeProvider) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := f.ResolveContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return artifact.URL, nil
+}
+
+func (f *fakeProvider) Resolve() (*jarchive.Artifact, error) {
+	return f.ResolveContext(context.Background())
+}
+
+func (f *fakeProvider) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	switch f.version {
+	case "1.20.4":
+		return &jarchive.Artifact{
+			URL:      fakeServerURL + "/server.jar",
+			FileName: "fake-1.20.4.jar",
+			Provider: "fake",
+			Kind:     jarchive.KindServer,
+			Version:  f.version,
+		}, nil
+	case "offline":
+		return nil, jarchive.ErrOffline
+	case "outage":
+		return nil, &jarchive.UpstreamError{Method: http.MethodGet, URL: fakeServerURL, StatusCode: http.StatusBadGateway}
+	default:
+		return nil, jarchive.ErrVersionNotFound
+	}
+}
+
+func (f *fakeProvider) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	return []jarchive.Version{
+		{ID: "1.20.4", Type: jarchive.VersionRelease, ReleaseTime: time.Date(2023, 12, 7, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+func (f *fakeProvider) ListBuilds(ctx context.Context) ([]jarchive.Build, error) {
+	return []jarchive.Build{
+		{ID: "42", Channel: "default", Changes: []jarchive.Change{{Summary: "Fix things"}}},
+	}, nil
+}
+
+func TestMain(m *testing.M) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fakeUserAgent.Store(r.UserAgent())
+		w.Write(jarContent)
+	}))
+	fakeServerURL = server.URL
+
+	jarchive.Register("fake", func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+		fakeOptions = jarchive.NewOptions(opts...)
+		return &fakeProvider{version: version}, nil
+	})
+
+	code := m.Run()
+	server.Close()
+	os.Exit(code)
+}
+
+func runCLI(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_URL(t *testing.T) {
+	code, stdout, _ := runCLI("url", "fake", "1.20.4")
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, fakeServerURL+"/server.jar\n", stdout)
+}
+
+func TestRun_URLJSON(t *testing.T) {
+	code, stdout, _ := runCLI("url", "--json", "fake", "1.20.4")
+
+	assert.Equal(t, exitOK, code)
+	var artifact jarchive.Artifact
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &artifact))
+	assert.Equal(t, "fake-1.20.4.jar", artifact.FileName)
+}
+
+func TestRun_Download(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	code, stdout, _ := runCLI("download", "fake", "1.20.4", "-o", dest)
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, dest+"\n", stdout)
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestRun_DownloadUserAgent(t *testing.T) {
+	dir := t.TempDir()
+
+	code, _, _ := runCLI("download", "fake", "1.20.4", "-o", filepath.Join(dir, "default.jar"))
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, jarchive.DefaultUserAgent, fakeOptions.UserAgent)
+	assert.Equal(t, jarchive.DefaultUserAgent, fakeUserAgent.Load())
+
+	code, _, _ = runCLI("download", "fake", "1.20.4", "-o", filepath.Join(dir, "custom.jar"), "--user-agent", "my-panel/2.0")
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "my-panel/2.0", fakeOptions.UserAgent)
+	assert.Equal(t, "my-panel/2.0", fakeUserAgent.Load())
+}
+
+func TestRun_Versions(t *testing.T) {
+	code, stdout, _ := runCLI("versions", "fake")
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, "1.20.4  release  2023-12-07\n", stdout)
+}
+
+func TestRun_Builds(t *testing.T) {
+	code, stdout, _ := runCLI("builds", "fake", "1.20.4", "--json")
+
+	assert.Equal(t, exitOK, code)
+	var builds []jarchive.Build
+	assert.NoError(t, json.Unmarshal([]byte(stdout), &builds))
+	assert.Equal(t, "42", builds[0].ID)
+}
+
+func TestRun_Providers(t *testing.T) {
+	code, stdout, _ := runCLI("providers")
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, []string{"fabric", "fake", "folia", "forge", "paper", "purpur", "vanilla", "velocity", "waterfall"}, strings.Fields(stdout))
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"no command", nil, exitUsage},
+		{"unknown command", []string{"frobnicate"}, exitUsage},
+		{"missing version", []string{"url", "fake"}, exitUsage},
+		{"unknown flag", []string{"url", "--nope", "fake", "1.20.4"}, exitUsage},
+		{"unknown provider", []string{"url", "nope", "1.20.4"}, exitNotFound},
+		{"unknown version", []string{"url", "fake", "0.0.0"}, exitNotFound},
+		{"upstream outage", []string{"url", "fake", "outage"}, exitUpstream},
+		{"not cached offline", []string{"url", "fake", "offline"}, exitUpstream},
+		{"offline without cache", []string{"url", "--offline", "fake", "1.20.4"}, exitUsage},
+		{"offline download", []string{"download", "--offline", "--cache-dir", os.TempDir(), "fake", "1.20.4"}, exitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runCLI(tt.args...)
+			assert.Equal(t, tt.expected, code)
+			assert.NotEmpty(t, stderr)
+		})
+	}
+}
+
+func TestRun_CacheDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	code, _, _ := runCLI("url", "--cache-dir", dir, "fake", "1.20.4")
+
+	assert.Equal(t, exitOK, code)
+	assert.DirExists(t, dir)
+}
@@ -0,0 +1,359 @@
+package jarchive
+
+import (
+	"cmp"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultRetryDelay = time.Second
+
+// ErrChecksumMismatch is matched by errors.Is for every *ChecksumError.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumError is returned when a downloaded file does not match the
+// checksum reported by the upstream API.
+type ChecksumError struct {
+	Algorithm HashAlgorithm
+	Expected  string
+	Actual    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: expected %s, got %s", e.Algorithm, e.Expected, e.Actual)
+}
+
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// Progress is a snapshot of a running download.
+type Progress struct {
+	Done  int64   // bytes written to disk, including resumed bytes
+	Total int64   // expected size in bytes, or -1 if unknown
+	Rate  float64 // bytes per second transferred during this download
+}
+
+// Downloader fetches artifacts to disk.
+//
+// The zero value is ready to use: it downloads with http.DefaultClient and
+// DefaultUserAgent, reports no progress and does not retry.
+type Downloader struct {
+	Client *http.Client
+
+	// UserAgent is sent with every request. It defaults to DefaultUserAgent.
+	UserAgent string
+
+	// Progress, if set, is called every time data is written to disk.
+	Progress func(Progress)
+
+	// MaxRetries bounds how many times an interrupted transfer is resumed.
+	MaxRetries int
+
+	// RetryDelay is the pause before each retry, multiplied by the attempt
+	// number. It defaults to one second.
+	RetryDelay time.Duration
+}
+
+// Download fetches artifact to destPath using a zero Downloader.
+func Download(ctx context.Context, artifact *Artifact, destPath string) error {
+	return new(Downloader).Download(ctx, artifact, destPath)
+}
+
+// Download streams artifact into destPath + ".part", verifies it against the
+// artifact checksum when one is known, and renames it into place. destPath
+// is left untouched if anything goes wrong.
+//
+// A ".part" file left behind by an interrupted download of the same URL is
+// resumed with an HTTP Range request. The ETag or Last-Modified of the first
+// response is sent as If-Range, so a server whose file changed in the
+// meantime sends it whole; servers that ignore the range restart from
+// scratch as well.
+func (d *Downloader) Download(ctx context.Context, artifact *Artifact, destPath string) error {
+	var h hash.Hash
+	if artifact.Checksum != "" {
+		var err error
+		if h, err = artifact.ChecksumAlgorithm.newHash(); err != nil {
+			return err
+		}
+	}
+
+	tmpPath := destPath + ".part"
+	t := &transfer{start: time.Now(), progress: d.Progress}
+	for attempt := 1; ; attempt++ {
+		retry, err := d.fetch(ctx, artifact, tmpPath, t)
+		if err == nil {
+			break
+		}
+		if !retry || attempt > d.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.retryDelay() * time.Duration(attempt)):
+		}
+	}
+
+	if h != nil {
+		if err := verifyChecksum(tmpPath, h, artifact); err != nil {
+			removePart(tmpPath)
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		return err
+	}
+	os.Remove(partInfoPath(tmpPath))
+	return nil
+}
+
+// fetch appends the missing part of artifact to tmpPath. The returned bool
+// reports whether the failure is transient and worth retrying.
+func (d *Downloader) fetch(ctx context.Context, artifact *Artifact, tmpPath string, t *transfer) (bool, error) {
+	// Only resume a partial file started from the same URL; anything else
+	// holds bytes of another file.
+	var offset int64
+	var info *partInfo
+	if stat, err := os.Stat(tmpPath); err == nil && stat.Size() > 0 {
+		info = readPartInfo(partInfoPath(tmpPath))
+		if info != nil && info.URL == artifact.URL {
+			offset = stat.Size()
+		} else {
+			removePart(tmpPath)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifact.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("User-Agent", cmp.Or(d.UserAgent, DefaultUserAgent))
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator := info.validator(); validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	total := artifact.Size
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		if offset == 0 || contentRangeStart(resp) != offset {
+			return true, fmt.Errorf("failed to resume %s: unexpected Content-Range %q", artifact.URL, resp.Header.Get("Content-Range"))
+		}
+		flags |= os.O_APPEND
+		if resp.ContentLength >= 0 {
+			total = offset + resp.ContentLength
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		if contentRangeSize(resp) == offset {
+			// A previous attempt received everything but stopped before
+			// the rename.
+			t.done, t.total = offset, offset
+			return false, nil
+		}
+		// The partial file is unusable; start over on the next attempt.
+		removePart(tmpPath)
+		return true, fmt.Errorf("failed to resume %s: status code %d", artifact.URL, resp.StatusCode)
+	case resp.StatusCode > 399:
+		err := &UpstreamError{Method: req.Method, URL: artifact.URL, StatusCode: resp.StatusCode}
+		return err.Temporary(), err
+	default:
+		flags |= os.O_TRUNC
+		offset = 0
+		if resp.ContentLength >= 0 {
+			total = resp.ContentLength
+		}
+		started := partInfo{
+			URL:          artifact.URL,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if err := started.write(partInfoPath(tmpPath)); err != nil {
+			return false, err
+		}
+	}
+	if total <= 0 {
+		total = -1
+	}
+
+	f, err := os.OpenFile(tmpPath, flags, 0o644)
+	if err != nil {
+		return false, err
+	}
+
+	t.done = offset
+	t.total = total
+	_, err = io.Copy(io.MultiWriter(f, t), resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return true, err
+	}
+
+	return false, nil
+}
+
+func (d *Downloader) client() *http.Client {
+	if d.Client != nil {
+		return d.Client
+	}
+	return http.DefaultClient
+}
+
+func (d *Downloader) retryDelay() time.Duration {
+	if d.RetryDelay > 0 {
+		return d.RetryDelay
+	}
+	return defaultRetryDelay
+}
+
+// contentRangeStart returns the first byte position of a 206 response, or -1
+// if the Content-Range header cannot be parsed.
+func contentRangeStart(resp *http.Response) int64 {
+	var first, last int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/", &first, &last); err != nil {
+		return -1
+	}
+	return first
+}
+
+// contentRangeSize returns the complete length from the "bytes */N"
+// Content-Range of a 416 response, or -1 if it cannot be parsed.
+func contentRangeSize(resp *http.Response) int64 {
+	var size int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes */%d", &size); err != nil {
+		return -1
+	}
+	return size
+}
+
+// partInfo records which response a ".part" file was started from. It is
+// stored next to the file so a later download can tell whether resuming it
+// is safe.
+type partInfo struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func partInfoPath(tmpPath string) string {
+	return tmpPath + ".json"
+}
+
+// readPartInfo returns nil if the file is missing or unreadable.
+func readPartInfo(path string) *partInfo {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	info := new(partInfo)
+	if err := json.Unmarshal(data, info); err != nil {
+		return nil
+	}
+	return info
+}
+
+func (info partInfo) write(path string) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// validator returns the If-Range value for resuming, preferring a strong
+// ETag; weak ETags are not allowed in If-Range.
+func (info *partInfo) validator() string {
+	if info.ETag != "" && !strings.HasPrefix(info.ETag, "W/") {
+		return info.ETag
+	}
+	return info.LastModified
+}
+
+// removePart deletes a partial download and its partInfo.
+func removePart(tmpPath string) {
+	os.Remove(tmpPath)
+	os.Remove(partInfoPath(tmpPath))
+}
+
+func verifyChecksum(path string, h hash.Hash, artifact *Artifact) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+
+	actual := hex.EncodeToString(h.Sum(nil))
+	if !strings.EqualFold(actual, artifact.Checksum) {
+		return &ChecksumError{
+			Algorithm: artifact.ChecksumAlgorithm,
+			Expected:  artifact.Checksum,
+			Actual:    actual,
+		}
+	}
+	return nil
+}
+
+// transfer tracks progress across all attempts of a single download.
+type transfer struct {
+	start    time.Time
+	received int64 // bytes received over the network since start
+	done     int64
+	total    int64
+	progress func(Progress)
+}
+
+func (t *transfer) Write(b []byte) (int, error) {
+	t.received += int64(len(b))
+	t.done += int64(len(b))
+	if t.progress != nil {
+		var rate float64
+		if elapsed := time.Since(t.start).Seconds(); elapsed > 0 {
+			rate = float64(t.received) / elapsed
+		}
+		t.progress(Progress{Done: t.done, Total: t.total, Rate: rate})
+	}
+	return len(b), nil
+}
+
+// newHash returns a hash for a, or an error if a is not supported.
+func (a HashAlgorithm) newHash() (hash.Hash, error) {
+	switch a {
+	case SHA1:
+		return sha1.New(), nil
+	case SHA256:
+		return sha256.New(), nil
+	case MD5:
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm: %s", a)
+	}
+}
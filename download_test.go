@@ -0,0 +1,319 @@
+package jarchive
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var jarContent = []byte("not really a jar")
+
+func jarServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(jarContent)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// rangeServer serves jarContent with Range support.
+func rangeServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownload_Success(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	artifact := &Artifact{URL: server.URL, Checksum: sha256Hex(jarContent), ChecksumAlgorithm: SHA256}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.NoError(t, err)
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+	assert.NoFileExists(t, dest+".part")
+}
+
+func TestDownload_MD5(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	sum := md5.Sum(jarContent)
+	artifact := &Artifact{URL: server.URL, Checksum: hex.EncodeToString(sum[:]), ChecksumAlgorithm: MD5}
+	assert.NoError(t, Download(context.Background(), artifact, dest))
+
+	artifact.Checksum = "0123456789abcdef0123456789abcdef"
+	err := Download(context.Background(), artifact, filepath.Join(t.TempDir(), "other.jar"))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestDownload_NoChecksum(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	err := Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.NoError(t, err)
+	assert.FileExists(t, dest)
+}
+
+func TestDownload_ChecksumMismatch(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	artifact := &Artifact{URL: server.URL, Checksum: "deadbeef", ChecksumAlgorithm: SHA1}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	var checksumErr *ChecksumError
+	assert.True(t, errors.As(err, &checksumErr))
+	assert.Equal(t, SHA1, checksumErr.Algorithm)
+	assert.Equal(t, "deadbeef", checksumErr.Expected)
+	assert.NoFileExists(t, dest)
+	assert.NoFileExists(t, dest+".part")
+}
+
+func TestDownload_UnsupportedAlgorithm(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	artifact := &Artifact{URL: server.URL, Checksum: "deadbeef", ChecksumAlgorithm: "crc32"}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported checksum algorithm")
+}
+
+func TestDownload_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	err := Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status code 404")
+	assert.NoFileExists(t, dest)
+}
+
+func TestDownload_Resume(t *testing.T) {
+	var gotRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", jarContent[:5], 0o644))
+	assert.NoError(t, partInfo{URL: server.URL}.write(dest+".part.json"))
+
+	artifact := &Artifact{URL: server.URL, Checksum: sha256Hex(jarContent), ChecksumAlgorithm: SHA256}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "bytes=5-", gotRange)
+	assert.NoFileExists(t, dest+".part.json")
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_UserAgent(t *testing.T) {
+	var gotUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.UserAgent()
+		w.Write(jarContent)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	assert.NoError(t, Download(context.Background(), &Artifact{URL: server.URL}, filepath.Join(dir, "default.jar")))
+	assert.Equal(t, DefaultUserAgent, gotUserAgent)
+
+	d := &Downloader{UserAgent: "my-panel/2.0 (ops@example.com)"}
+	assert.NoError(t, d.Download(context.Background(), &Artifact{URL: server.URL}, filepath.Join(dir, "custom.jar")))
+	assert.Equal(t, "my-panel/2.0 (ops@example.com)", gotUserAgent)
+}
+
+func TestDownload_ResumeDifferentURL(t *testing.T) {
+	var gotRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", []byte("other"), 0o644))
+	assert.NoError(t, partInfo{URL: server.URL + "/other.jar"}.write(dest+".part.json"))
+
+	err := Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.NoError(t, err)
+	assert.Empty(t, gotRange)
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_ResumeChangedFile(t *testing.T) {
+	var gotIfRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIfRange = r.Header.Get("If-Range")
+		w.Header().Set("ETag", `"new"`)
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", []byte("old"), 0o644))
+	assert.NoError(t, partInfo{URL: server.URL, ETag: `"old"`}.write(dest+".part.json"))
+
+	err := Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, `"old"`, gotIfRange)
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_ResumeComplete(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", jarContent, 0o644))
+	assert.NoError(t, partInfo{URL: server.URL, ETag: `"v1"`}.write(dest+".part.json"))
+
+	artifact := &Artifact{URL: server.URL, Checksum: sha256Hex(jarContent), ChecksumAlgorithm: SHA256}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_ResumeWrongRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Range", "bytes 0-4/16")
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(jarContent[:5])
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", jarContent[:8], 0o644))
+	assert.NoError(t, partInfo{URL: server.URL}.write(dest+".part.json"))
+
+	err := Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.ErrorContains(t, err, "unexpected Content-Range")
+	assert.NoFileExists(t, dest)
+	data, err := os.ReadFile(dest + ".part")
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent[:8], data)
+}
+
+func TestDownload_RangeIgnored(t *testing.T) {
+	server := jarServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+	assert.NoError(t, os.WriteFile(dest+".part", []byte("garbage"), 0o644))
+	assert.NoError(t, partInfo{URL: server.URL}.write(dest+".part.json"))
+
+	artifact := &Artifact{URL: server.URL, Checksum: sha256Hex(jarContent), ChecksumAlgorithm: SHA256}
+	err := Download(context.Background(), artifact, dest)
+
+	assert.NoError(t, err)
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_Progress(t *testing.T) {
+	server := rangeServer(t)
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	var last Progress
+	d := &Downloader{Progress: func(p Progress) { last = p }}
+	err := d.Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(jarContent)), last.Done)
+	assert.Equal(t, int64(len(jarContent)), last.Total)
+	assert.Greater(t, last.Rate, 0.0)
+}
+
+func TestDownload_RetryAfterDrop(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Promise the full body but hang up halfway through.
+			w.Header().Set("Content-Length", strconv.Itoa(len(jarContent)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(jarContent[:8])
+			return
+		}
+		http.ServeContent(w, r, "server.jar", time.Time{}, bytes.NewReader(jarContent))
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	d := &Downloader{MaxRetries: 2, RetryDelay: time.Millisecond}
+	artifact := &Artifact{URL: server.URL, Checksum: sha256Hex(jarContent), ChecksumAlgorithm: SHA256}
+	err := d.Download(context.Background(), artifact, dest)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+	data, err := os.ReadFile(dest)
+	assert.NoError(t, err)
+	assert.Equal(t, jarContent, data)
+}
+
+func TestDownload_RetriesExhausted(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	dest := filepath.Join(t.TempDir(), "server.jar")
+
+	d := &Downloader{MaxRetries: 2, RetryDelay: time.Millisecond}
+	err := d.Download(context.Background(), &Artifact{URL: server.URL}, dest)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status code 502")
+	assert.Equal(t, int32(3), requests.Load())
+}
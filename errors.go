@@ -0,0 +1,47 @@
+package jarchive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrUnknownProvider means no provider is registered under the requested
+	// name.
+	ErrUnknownProvider = errors.New("unknown provider")
+
+	// ErrVersionNotFound means the provider does not know the requested
+	// Minecraft version.
+	ErrVersionNotFound = errors.New("version not found")
+
+	// ErrBuildNotFound means the version exists but the requested build,
+	// loader or Forge version does not, or the version has no builds at all.
+	ErrBuildNotFound = errors.New("build not found")
+
+	// ErrNoServerArtifact means the version exists but does not ship the
+	// requested server download, or whichever other file was requested.
+	ErrNoServerArtifact = errors.New("no server artifact")
+
+	// ErrOffline means offline mode is enabled and the answer is not in the
+	// cache.
+	ErrOffline = errors.New("not available offline")
+)
+
+// UpstreamError is returned when an upstream API answers with an error
+// status that the provider cannot attribute to the request itself.
+type UpstreamError struct {
+	Method     string
+	URL        string
+	StatusCode int
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status code %d", e.Method, e.URL, e.StatusCode)
+}
+
+// Temporary reports whether the status suggests the request may succeed if
+// retried later.
+func (e *UpstreamError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode > 499
+}
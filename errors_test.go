@@ -0,0 +1,33 @@
+package jarchive
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamError_Error(t *testing.T) {
+	err := &UpstreamError{Method: http.MethodGet, URL: "https://example.com/versions", StatusCode: http.StatusBadGateway}
+	assert.Equal(t, "GET https://example.com/versions: unexpected status code 502", err.Error())
+}
+
+func TestUpstreamError_Temporary(t *testing.T) {
+	tests := []struct {
+		code     int
+		expected bool
+	}{
+		{http.StatusNotFound, false},
+		{http.StatusBadRequest, false},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			err := &UpstreamError{StatusCode: tt.code}
+			assert.Equal(t, tt.expected, err.Temporary())
+		})
+	}
+}
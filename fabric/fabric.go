@@ -1,42 +1,341 @@
 package fabric
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
+	"github.com/ciathefed/jarchive/internal/utils"
+	"github.com/ciathefed/jarchive/vanilla"
+)
+
+const providerName = "fabric"
+
+const defaultBaseURL = "https://meta.fabricmc.net"
+
+var (
+	// ErrUnknownGameVersion means Fabric Meta does not list the requested
+	// Minecraft version. It matches jarchive.ErrVersionNotFound.
+	ErrUnknownGameVersion = fmt.Errorf("%w: unknown Fabric game version", jarchive.ErrVersionNotFound)
+
+	// ErrUnsupportedLoader means the pinned loader version does not exist or
+	// does not support the requested Minecraft version. It matches
+	// jarchive.ErrBuildNotFound.
+	ErrUnsupportedLoader = fmt.Errorf("%w: unsupported Fabric loader", jarchive.ErrBuildNotFound)
+
+	// ErrUnknownInstaller means Fabric Meta does not list the pinned
+	// installer version. It matches jarchive.ErrBuildNotFound.
+	ErrUnknownInstaller = fmt.Errorf("%w: unknown Fabric installer", jarchive.ErrBuildNotFound)
 )
 
 var (
-	downloadURLFormat       = "https://meta.fabricmc.net/v2/versions/loader/%s/%s/%s/server/jar"
-	defaultLoaderVersion    = "0.16.10"
-	defaultInstallerVersion = "1.0.1"
+	_ jarchive.Jarchive      = (*Config)(nil)
+	_ jarchive.VersionLister = (*Config)(nil)
 )
 
+// gameVersion is an entry of Fabric Meta's game version list.
+type gameVersion struct {
+	Version string `json:"version"`
+	Stable  bool   `json:"stable"`
+}
+
 type Config struct {
-	Version          string
+	Version string
+
+	// LoaderVersion and InstallerVersion pin the Fabric loader and installer
+	// the server launcher is built from. When empty, the newest stable ones
+	// Fabric Meta offers for Version are used.
 	LoaderVersion    string
 	InstallerVersion string
+
+	client         *httputil.Client
+	baseURL        string
+	unstableLoader bool
+	vanillaOptions []vanilla.Option
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHTTPClient is the provider-level form of jarchive.WithHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return withOptions(jarchive.WithHTTPClient(client))
+}
+
+// WithCache is the provider-level form of jarchive.WithCache.
+func WithCache(store cache.Cache, ttl time.Duration) Option {
+	return withOptions(jarchive.WithCache(store, ttl))
+}
+
+// WithOffline is the provider-level form of jarchive.WithOffline.
+func WithOffline(offline bool) Option {
+	return withOptions(jarchive.WithOffline(offline))
+}
+
+// WithRetryPolicy is the provider-level form of jarchive.WithRetryPolicy.
+func WithRetryPolicy(policy jarchive.RetryPolicy) Option {
+	return withOptions(jarchive.WithRetryPolicy(policy))
 }
 
-func New(version string) *Config {
-	return &Config{
-		Version:          version,
-		LoaderVersion:    defaultLoaderVersion,
-		InstallerVersion: defaultInstallerVersion,
+// WithUserAgent is the provider-level form of jarchive.WithUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return withOptions(jarchive.WithUserAgent(userAgent))
+}
+
+// WithUnstableLoader lets the newest loader be picked even if Fabric has not
+// marked it stable. It has no effect when LoaderVersion is set.
+func WithUnstableLoader(allow bool) Option {
+	return func(c *Config) {
+		c.unstableLoader = allow
+	}
+}
+
+// WithVanillaOptions adds options for the vanilla provider PrepareServer
+// resolves the Minecraft server jar with. It already shares the HTTP client,
+// cache, offline mode, retry policy and User-Agent of c.
+func WithVanillaOptions(opts ...vanilla.Option) Option {
+	return func(c *Config) {
+		c.vanillaOptions = append(c.vanillaOptions, opts...)
+	}
+}
+
+// WithBaseURL sets the Fabric Meta base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) {
+		c.baseURL = url
+	}
+}
+
+func New(version string, opts ...Option) *Config {
+	c := &Config{
+		Version: version,
+		client:  httputil.NewClient(),
+		baseURL: defaultBaseURL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func init() {
+	jarchive.Register(providerName, func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+		return New(version, withOptions(opts...)), nil
+	})
+}
+
+// withOptions applies the provider-independent jarchive options.
+func withOptions(opts ...jarchive.Option) Option {
+	return func(c *Config) {
+		c.client.Apply(opts...)
 	}
 }
 
 func (c *Config) Mirror() (string, error) {
-	url := fmt.Sprintf(downloadURLFormat, c.Version, c.LoaderVersion, c.InstallerVersion)
+	return c.MirrorContext(context.Background())
+}
 
-	resp, err := http.Head(url)
+func (c *Config) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := c.ResolveContext(ctx)
 	if err != nil {
 		return "", err
 	}
+	return artifact.URL, nil
+}
+
+// Resolve returns the Fabric server launcher for the configured versions,
+// looking up the loader and installer versions that are not pinned. The game
+// version and any pinned versions are checked against Fabric Meta first.
+func (c *Config) Resolve() (*jarchive.Artifact, error) {
+	return c.ResolveContext(context.Background())
+}
+
+// ResolveContext is like Resolve but honors the deadline and cancellation of ctx.
+func (c *Config) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	if err := c.checkGameVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	loaderVersion, err := c.getLoaderVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	installerVersion, err := c.getInstallerVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := utils.URLJoin(
+		c.baseURL,
+		"v2/versions/loader",
+		c.Version,
+		loaderVersion,
+		installerVersion,
+		"server/jar",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Head(ctx, url)
+	if httputil.HasStatus(err, http.StatusBadRequest, http.StatusNotFound) {
+		return nil, fmt.Errorf("%w: %s with loader %s and installer %s: %w", jarchive.ErrNoServerArtifact, c.Version, loaderVersion, installerVersion, err)
+	}
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid version")
+	artifact := &jarchive.Artifact{
+		URL: url,
+		FileName: fmt.Sprintf(
+			"fabric-server-mc.%s-loader.%s-launcher.%s.jar",
+			c.Version,
+			loaderVersion,
+			installerVersion,
+		),
+		Provider: providerName,
+		Kind:     jarchive.KindLauncher,
+		Version:  c.Version,
+		Build:    loaderVersion,
+	}
+	if resp.ContentLength > 0 {
+		artifact.Size = resp.ContentLength
+	}
+
+	return artifact, nil
+}
+
+// checkGameVersion makes sure Fabric Meta knows the configured game version.
+func (c *Config) checkGameVersion(ctx context.Context) error {
+	versions, err := c.getGameVersions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range versions {
+		if v.Version == c.Version {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrUnknownGameVersion, c.Version)
+}
+
+// getLoaderVersion returns the pinned loader version once Fabric Meta confirms
+// it supports the configured game version, or else the newest loader that
+// does, skipping unstable ones unless allowed.
+func (c *Config) getLoaderVersion(ctx context.Context) (string, error) {
+	url, err := utils.URLJoin(c.baseURL, "v2/versions/loader", c.Version)
+	if err != nil {
+		return "", err
+	}
+
+	var data []struct {
+		Loader struct {
+			Version string `json:"version"`
+			Stable  bool   `json:"stable"`
+		} `json:"loader"`
+	}
+	err = c.client.GetJSON(ctx, url, &data)
+	if httputil.HasStatus(err, http.StatusBadRequest, http.StatusNotFound) {
+		return "", fmt.Errorf("%w: %s: %w", ErrUnknownGameVersion, c.Version, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if c.LoaderVersion != "" {
+		for _, v := range data {
+			if v.Loader.Version == c.LoaderVersion {
+				return c.LoaderVersion, nil
+			}
+		}
+		return "", fmt.Errorf("%w: loader %s does not support Minecraft %s", ErrUnsupportedLoader, c.LoaderVersion, c.Version)
+	}
+
+	if len(data) == 0 {
+		return "", fmt.Errorf("%w: no Fabric loader supports Minecraft %s", ErrUnsupportedLoader, c.Version)
+	}
+
+	// Fabric Meta lists loaders newest first.
+	for _, v := range data {
+		if v.Loader.Stable || c.unstableLoader {
+			return v.Loader.Version, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: no stable Fabric loader supports Minecraft %s", ErrUnsupportedLoader, c.Version)
+}
+
+// getInstallerVersion returns the pinned installer version once Fabric Meta
+// confirms it exists, or else the newest stable installer.
+func (c *Config) getInstallerVersion(ctx context.Context) (string, error) {
+	url, err := utils.URLJoin(c.baseURL, "v2/versions/installer")
+	if err != nil {
+		return "", err
+	}
+
+	var data []struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	if err := c.client.GetJSON(ctx, url, &data); err != nil {
+		return "", err
+	}
+
+	for _, v := range data {
+		if c.InstallerVersion != "" && v.Version == c.InstallerVersion {
+			return v.Version, nil
+		}
+		if c.InstallerVersion == "" && v.Stable {
+			return v.Version, nil
+		}
+	}
+
+	if c.InstallerVersion != "" {
+		return "", fmt.Errorf("%w: %s", ErrUnknownInstaller, c.InstallerVersion)
+	}
+	return "", fmt.Errorf("%w: no stable Fabric installer found", ErrUnknownInstaller)
+}
+
+// getGameVersions fetches the game versions known to Fabric Meta, newest
+// first.
+func (c *Config) getGameVersions(ctx context.Context) ([]gameVersion, error) {
+	url, err := utils.URLJoin(c.baseURL, "v2/versions/game")
+	if err != nil {
+		return nil, err
+	}
+
+	var data []gameVersion
+	if err := c.client.GetJSON(ctx, url, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ListVersions returns the game versions known to Fabric Meta. Versions Fabric
+// does not mark as stable are reported as snapshots.
+func (c *Config) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	data, err := c.getGameVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]jarchive.Version, 0, len(data))
+	for _, v := range data {
+		version := jarchive.Version{ID: v.Version, Type: jarchive.VersionRelease}
+		if !v.Stable {
+			version.Type = jarchive.VersionSnapshot
+		}
+		versions = append(versions, version)
 	}
 
-	return url, nil
+	return versions, nil
 }
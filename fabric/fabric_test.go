@@ -1,29 +1,118 @@
 package fabric
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
 	"github.com/stretchr/testify/assert"
 )
 
+// noRetry keeps tests of failing upstreams fast.
+var noRetry = WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 1})
+
+// metaHandler serves the game versions 22w11a and 1.18.2, loaders and
+// installers, newest first, a 1.18.2 server jar for every loader and
+// installer combination, the server profile of loader 0.16.10 with its
+// libraries below /maven, and Mojang's 1.18.2 server below /mojang.
+func metaHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/versions/game":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"version": "22w11a", "stable": false},
+				{"version": "1.18.2", "stable": true},
+			})
+		case r.URL.Path == "/v2/versions/loader/22w11a":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("[]"))
+		case r.URL.Path == "/v2/versions/loader/1.18.2":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"loader": map[string]any{"version": "0.17.0-beta.1", "stable": false}},
+				{"loader": map[string]any{"version": "0.16.10", "stable": true}},
+				{"loader": map[string]any{"version": "0.16.9", "stable": true}},
+			})
+		case r.URL.Path == "/v2/versions/installer":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"version": "1.1.0", "stable": false},
+				{"version": "1.0.1", "stable": true},
+			})
+		case r.URL.Path == "/v2/versions/loader/1.18.2/0.16.10/server/json":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]any{
+				"mainClass": "net.fabricmc.loader.impl.launch.knot.KnotServer",
+				"libraries": testLibraries(r),
+			})
+		case strings.HasPrefix(r.URL.Path, "/maven/"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(path.Base(r.URL.Path)))
+		case strings.HasPrefix(r.URL.Path, "/v2/versions/loader/1.18.2/") && strings.HasSuffix(r.URL.Path, "/server/jar"):
+			w.Header().Set("Content-Length", "1024")
+			w.WriteHeader(http.StatusOK)
+			w.Write(make([]byte, 1024))
+		case strings.HasPrefix(r.URL.Path, "/mojang/"):
+			mojangHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
+
+func newMetaServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(metaHandler())
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestNew(t *testing.T) {
 	config := New("1.18.2")
 	assert.Equal(t, "1.18.2", config.Version)
-	assert.Equal(t, "0.16.10", config.LoaderVersion)
-	assert.Equal(t, "1.0.1", config.InstallerVersion)
+	assert.Empty(t, config.LoaderVersion)
+	assert.Empty(t, config.InstallerVersion)
+	assert.False(t, config.unstableLoader)
+	assert.Equal(t, http.DefaultClient, config.client.HTTP)
+	assert.Nil(t, config.client.Cache)
+	assert.Equal(t, defaultBaseURL, config.baseURL)
 }
 
-func TestMirror_Success(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
+func TestNew_WithOptions(t *testing.T) {
+	client := &http.Client{}
+	store := cache.NewMemory()
+	config := New("1.18.2", WithHTTPClient(client), WithCache(store, time.Minute), WithOffline(true), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}), WithUnstableLoader(true), WithBaseURL("https://meta.example.com"))
+	assert.Same(t, client, config.client.HTTP)
+	assert.Same(t, store, config.client.Cache)
+	assert.Equal(t, time.Minute, config.client.TTL)
+	assert.Equal(t, "https://meta.example.com", config.baseURL)
+	assert.True(t, config.client.Offline)
+	assert.Equal(t, 5, config.client.Retry.MaxAttempts)
+	assert.True(t, config.unstableLoader)
+}
 
-	downloadURLFormat = server.URL + "/v2/versions/loader/%s/%s/%s/server/jar"
+func TestRegistered(t *testing.T) {
+	client := &http.Client{}
+	provider, err := jarchive.New("fabric", "1.18.2", jarchive.WithHTTPClient(client))
 
-	config := New("1.18.2")
+	assert.NoError(t, err)
+	config, ok := provider.(*Config)
+	assert.True(t, ok)
+	assert.Equal(t, "1.18.2", config.Version)
+	assert.Same(t, client, config.client.HTTP)
+}
+
+func TestMirror_Success(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
 	mirrorURL, err := config.Mirror()
 
 	assert.NoError(t, err)
@@ -32,48 +121,193 @@ func TestMirror_Success(t *testing.T) {
 }
 
 func TestMirror_CustomLoaderAndInstallerVersions(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
+	config.LoaderVersion = "0.16.9"
+	config.InstallerVersion = "1.1.0"
+	mirrorURL, err := config.Mirror()
+
+	assert.NoError(t, err)
+	expectedURL := server.URL + "/v2/versions/loader/1.18.2/0.16.9/1.1.0/server/jar"
+	assert.Equal(t, expectedURL, mirrorURL)
+}
+
+func TestMirror_InvalidVersion(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("invalid-version", WithBaseURL(server.URL))
+	_, err := config.Mirror()
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, ErrUnknownGameVersion)
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+}
+
+func TestMirror_NetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	}))
+	server.Close()
+
+	config := New("1.18.2", WithBaseURL(server.URL), noRetry)
+	_, err := config.Mirror()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+}
+
+func TestMirrorContext_Canceled(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
 
-	downloadURLFormat = server.URL + "/v2/versions/loader/%s/%s/%s/server/jar"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	config := New("1.18.2")
-	config.LoaderVersion = "0.15.0"
-	config.InstallerVersion = "0.9.0"
-	mirrorURL, err := config.Mirror()
+	config := New("1.18.2", WithBaseURL(server.URL))
+	_, err := config.MirrorContext(ctx)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolve_Success(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
+	artifact, err := config.Resolve()
 
 	assert.NoError(t, err)
-	expectedURL := server.URL + "/v2/versions/loader/1.18.2/0.15.0/0.9.0/server/jar"
-	assert.Equal(t, expectedURL, mirrorURL)
+	assert.Equal(t, server.URL+"/v2/versions/loader/1.18.2/0.16.10/1.0.1/server/jar", artifact.URL)
+	assert.Equal(t, "fabric-server-mc.1.18.2-loader.0.16.10-launcher.1.0.1.jar", artifact.FileName)
+	assert.Equal(t, "fabric", artifact.Provider)
+	assert.Equal(t, jarchive.KindLauncher, artifact.Kind)
+	assert.Equal(t, "1.18.2", artifact.Version)
+	assert.Equal(t, "0.16.10", artifact.Build)
+	assert.Equal(t, int64(1024), artifact.Size)
 }
 
-func TestMirror_InvalidVersion(t *testing.T) {
+func TestListVersions_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
+		assert.Equal(t, "/v2/versions/game", r.URL.Path)
+		response := []map[string]any{
+			{"version": "22w11a", "stable": false},
+			{"version": "1.18.2", "stable": true},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
 	}))
 	defer server.Close()
 
-	downloadURLFormat = server.URL + "/v2/versions/loader/%s/%s/%s/server/jar"
+	config := New("1.18.2", WithBaseURL(server.URL))
+	versions, err := config.ListVersions(context.Background())
 
-	config := New("invalid-version")
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Version{
+		{ID: "22w11a", Type: jarchive.VersionSnapshot},
+		{ID: "1.18.2", Type: jarchive.VersionRelease},
+	}, versions)
+}
+
+func TestMirror_UpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithBaseURL(server.URL), noRetry)
 	_, err := config.Mirror()
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+	assert.NotErrorIs(t, err, jarchive.ErrVersionNotFound)
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusBadGateway, upstreamErr.StatusCode)
 }
 
-func TestMirror_NetworkError(t *testing.T) {
+func TestMirror_RetriesRateLimit(t *testing.T) {
+	var requests int
+	meta := metaHandler()
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		meta.ServeHTTP(w, r)
 	}))
-	server.Close()
-
-	downloadURLFormat = server.URL + "/v2/versions/loader/%s/%s/%s/server/jar"
+	defer server.Close()
 
-	config := New("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}))
 	_, err := config.Mirror()
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "connection refused")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, requests)
+}
+
+func TestResolve_UnstableLoader(t *testing.T) {
+	server := newMetaServer(t)
+
+	artifact, err := New("1.18.2", WithBaseURL(server.URL), WithUnstableLoader(true)).Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0.17.0-beta.1", artifact.Build)
+	assert.Equal(t, server.URL+"/v2/versions/loader/1.18.2/0.17.0-beta.1/1.0.1/server/jar", artifact.URL)
+}
+
+func TestResolve_PinnedLoader(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL), WithUnstableLoader(true))
+	config.LoaderVersion = "0.16.9"
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/v2/versions/loader/1.18.2/0.16.9/1.0.1/server/jar", artifact.URL)
+}
+
+func TestResolve_NoLoaders(t *testing.T) {
+	server := newMetaServer(t)
+
+	_, err := New("22w11a", WithBaseURL(server.URL)).Resolve()
+
+	assert.ErrorIs(t, err, ErrUnsupportedLoader)
+}
+
+func TestResolve_UnsupportedLoader(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
+	config.LoaderVersion = "0.1.0"
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, ErrUnsupportedLoader)
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "loader 0.1.0 does not support Minecraft 1.18.2")
+}
+
+func TestResolve_UnknownInstaller(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
+	config.InstallerVersion = "9.9.9"
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, ErrUnknownInstaller)
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+}
+
+func TestGetLoaderVersion_NoStableLoader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"loader": map[string]any{"version": "0.17.0-beta.1", "stable": false}},
+		})
+	}))
+	defer server.Close()
+
+	_, err := New("1.18.2", WithBaseURL(server.URL)).getLoaderVersion(context.Background())
+
+	assert.ErrorIs(t, err, ErrUnsupportedLoader)
 }
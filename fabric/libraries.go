@@ -0,0 +1,185 @@
+package fabric
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/internal/httputil"
+	"github.com/ciathefed/jarchive/internal/utils"
+)
+
+// Library is a Maven library the Fabric server launcher loads on start.
+type Library struct {
+	Name string // Maven coordinates, e.g. "org.ow2.asm:asm:9.7"
+	Path string // slash-separated path below the libraries directory
+
+	*jarchive.Artifact
+}
+
+// serverProfile is the part of a Fabric Meta server profile the launcher
+// uses.
+type serverProfile struct {
+	MainClass string `json:"mainClass"`
+	Libraries []struct {
+		Name   string `json:"name"`
+		URL    string `json:"url"`
+		MD5    string `json:"md5"`
+		SHA1   string `json:"sha1"`
+		SHA256 string `json:"sha256"`
+		Size   int64  `json:"size"`
+	} `json:"libraries"`
+}
+
+// ListLibraries returns the libraries listed in the Fabric Meta server profile
+// of the configured game version and loader, which is resolved the same way
+// as in Resolve.
+func (c *Config) ListLibraries(ctx context.Context) ([]Library, error) {
+	loaderVersion, profile, err := c.getServerProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.libraries(loaderVersion, profile)
+}
+
+// getServerProfile returns the loader version Resolve would pick and its
+// server profile.
+func (c *Config) getServerProfile(ctx context.Context) (string, *serverProfile, error) {
+	if err := c.checkGameVersion(ctx); err != nil {
+		return "", nil, err
+	}
+
+	loaderVersion, err := c.getLoaderVersion(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	url, err := utils.URLJoin(c.baseURL, "v2/versions/loader", c.Version, loaderVersion, "server/json")
+	if err != nil {
+		return "", nil, err
+	}
+
+	profile := new(serverProfile)
+	err = c.client.GetJSON(ctx, url, profile)
+	if httputil.HasStatus(err, http.StatusBadRequest, http.StatusNotFound) {
+		return "", nil, fmt.Errorf("%w: server profile for %s with loader %s: %w", ErrUnsupportedLoader, c.Version, loaderVersion, err)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return loaderVersion, profile, nil
+}
+
+// libraries converts the libraries of profile into Library values.
+func (c *Config) libraries(loaderVersion string, profile *serverProfile) ([]Library, error) {
+	libraries := make([]Library, 0, len(profile.Libraries))
+	for _, lib := range profile.Libraries {
+		libPath, err := mavenPath(lib.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		artifact := &jarchive.Artifact{
+			URL:      strings.TrimSuffix(lib.URL, "/") + "/" + libPath,
+			FileName: path.Base(libPath),
+			Provider: providerName,
+			Kind:     jarchive.KindLibrary,
+			Version:  c.Version,
+			Build:    loaderVersion,
+			Size:     lib.Size,
+		}
+		switch {
+		case lib.SHA256 != "":
+			artifact.Checksum, artifact.ChecksumAlgorithm = lib.SHA256, jarchive.SHA256
+		case lib.SHA1 != "":
+			artifact.Checksum, artifact.ChecksumAlgorithm = lib.SHA1, jarchive.SHA1
+		case lib.MD5 != "":
+			artifact.Checksum, artifact.ChecksumAlgorithm = lib.MD5, jarchive.MD5
+		}
+
+		libraries = append(libraries, Library{Name: lib.Name, Path: libPath, Artifact: artifact})
+	}
+
+	return libraries, nil
+}
+
+// DownloadLibraries downloads every library from ListLibraries into
+// dir/libraries using the Maven repository layout the server launcher uses,
+// so it does not have to download them itself. A nil d downloads with the
+// HTTP client and User-Agent of c.
+//
+// The launcher still needs the network on its first start to fetch the
+// vanilla server jar and build its launch jar; PrepareServer stages those as
+// well.
+func (c *Config) DownloadLibraries(ctx context.Context, dir string, d *jarchive.Downloader) error {
+	libraries, err := c.ListLibraries(ctx)
+	if err != nil {
+		return err
+	}
+	return downloadLibraries(ctx, dir, c.downloader(d), libraries)
+}
+
+// downloader returns d, or a Downloader using the HTTP client and User-Agent
+// of c if d is nil.
+func (c *Config) downloader(d *jarchive.Downloader) *jarchive.Downloader {
+	if d != nil {
+		return d
+	}
+	return &jarchive.Downloader{Client: c.client.HTTP, UserAgent: c.client.UserAgent}
+}
+
+// downloadLibraries downloads libraries into dir/libraries.
+func downloadLibraries(ctx context.Context, dir string, d *jarchive.Downloader, libraries []Library) error {
+	root := filepath.Join(dir, "libraries")
+	for _, lib := range libraries {
+		dest := filepath.Join(root, filepath.FromSlash(lib.Path))
+		if rel, err := filepath.Rel(root, dest); err != nil || !filepath.IsLocal(rel) {
+			return fmt.Errorf("library %s escapes %s", lib.Name, root)
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		if err := d.Download(ctx, lib.Artifact, dest); err != nil {
+			return fmt.Errorf("failed to download library %s: %w", lib.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// mavenPath converts Maven coordinates of the form
+// group:artifact:version[:classifier][@extension] into the path of the file
+// in a Maven repository. The coordinates come from upstream, so parts that
+// could leave the repository, such as "..", are rejected.
+func mavenPath(coordinates string) (string, error) {
+	name, ext, found := strings.Cut(coordinates, "@")
+	if !found {
+		ext = "jar"
+	}
+
+	parts := strings.Split(name, ":")
+	if len(parts) < 3 || len(parts) > 4 || slices.ContainsFunc(parts, unsafePathPart) || unsafePathPart(ext) {
+		return "", fmt.Errorf("invalid Maven coordinates %q", coordinates)
+	}
+
+	group, artifact, version := parts[0], parts[1], parts[2]
+	file := artifact + "-" + version
+	if len(parts) == 4 {
+		file += "-" + parts[3]
+	}
+
+	return path.Join(strings.ReplaceAll(group, ".", "/"), artifact, version, file+"."+ext), nil
+}
+
+// unsafePathPart reports whether a part of Maven coordinates is empty or
+// could change directory when used in a path.
+func unsafePathPart(part string) bool {
+	return part == "" || strings.ContainsAny(part, `/\`) || strings.Contains(part, "..")
+}
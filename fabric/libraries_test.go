@@ -0,0 +1,170 @@
+package fabric
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLibraries is the library list of the server profile served by
+// metaHandler. Each library's content is its file name.
+func testLibraries(r *http.Request) []map[string]any {
+	maven := "http://" + r.Host + "/maven/"
+	return []map[string]any{
+		{"name": "net.fabricmc:fabric-loader:0.16.10", "url": maven, "sha256": checksum(sha256.New(), "fabric-loader-0.16.10.jar")},
+		{"name": "org.ow2.asm:asm:9.7.1", "url": maven, "sha1": checksum(sha1.New(), "asm-9.7.1.jar"), "size": 13},
+		{"name": "net.fabricmc:intermediary:1.18.2", "url": maven, "md5": checksum(md5.New(), "intermediary-1.18.2.jar")},
+		{"name": "net.fabricmc:sponge-mixin:0.15.4+mixin.0.8.7", "url": maven},
+	}
+}
+
+func checksum(h hash.Hash, data string) string {
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func TestMavenPath(t *testing.T) {
+	tests := []struct {
+		coordinates string
+		want        string
+	}{
+		{"org.ow2.asm:asm:9.7.1", "org/ow2/asm/asm/9.7.1/asm-9.7.1.jar"},
+		{"net.fabricmc:intermediary:1.18.2:v2", "net/fabricmc/intermediary/1.18.2/intermediary-1.18.2-v2.jar"},
+		{"net.fabricmc:yarn:1.18.2+build.4@zip", "net/fabricmc/yarn/1.18.2+build.4/yarn-1.18.2+build.4.zip"},
+	}
+	for _, tt := range tests {
+		got, err := mavenPath(tt.coordinates)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	for _, invalid := range []string{
+		"", "org.ow2.asm:asm", "org.ow2.asm::9.7.1", "a:b:c:d:e",
+		"g:a:../../../../etc/x", "g:a:1.0:../x", "g:a/b:1.0", `g:a:1.0\x`, "..:a:1.0", "g:a:1.0@../x",
+	} {
+		_, err := mavenPath(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestListLibraries_Success(t *testing.T) {
+	server := newMetaServer(t)
+
+	libraries, err := New("1.18.2", WithBaseURL(server.URL)).ListLibraries(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, libraries, 4)
+
+	asm := libraries[1]
+	assert.Equal(t, "org.ow2.asm:asm:9.7.1", asm.Name)
+	assert.Equal(t, "org/ow2/asm/asm/9.7.1/asm-9.7.1.jar", asm.Path)
+	assert.Equal(t, server.URL+"/maven/org/ow2/asm/asm/9.7.1/asm-9.7.1.jar", asm.URL)
+	assert.Equal(t, "asm-9.7.1.jar", asm.FileName)
+	assert.Equal(t, "fabric", asm.Provider)
+	assert.Equal(t, jarchive.KindLibrary, asm.Kind)
+	assert.Equal(t, "1.18.2", asm.Version)
+	assert.Equal(t, "0.16.10", asm.Build)
+	assert.Equal(t, int64(13), asm.Size)
+	assert.Equal(t, jarchive.SHA1, asm.ChecksumAlgorithm)
+
+	assert.Equal(t, jarchive.SHA256, libraries[0].ChecksumAlgorithm)
+	assert.Equal(t, jarchive.MD5, libraries[2].ChecksumAlgorithm)
+	assert.Empty(t, libraries[3].Checksum)
+}
+
+func TestListLibraries_UnsupportedLoader(t *testing.T) {
+	server := newMetaServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL))
+	config.LoaderVersion = "0.16.9"
+	_, err := config.ListLibraries(context.Background())
+
+	assert.ErrorIs(t, err, ErrUnsupportedLoader)
+}
+
+func TestDownloadLibraries_Success(t *testing.T) {
+	server := newMetaServer(t)
+	dir := t.TempDir()
+
+	err := New("1.18.2", WithBaseURL(server.URL)).DownloadLibraries(context.Background(), dir, nil)
+
+	assert.NoError(t, err)
+	for _, path := range []string{
+		"net/fabricmc/fabric-loader/0.16.10/fabric-loader-0.16.10.jar",
+		"org/ow2/asm/asm/9.7.1/asm-9.7.1.jar",
+		"net/fabricmc/intermediary/1.18.2/intermediary-1.18.2.jar",
+		"net/fabricmc/sponge-mixin/0.15.4+mixin.0.8.7/sponge-mixin-0.15.4+mixin.0.8.7.jar",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, "libraries", filepath.FromSlash(path)))
+		assert.NoError(t, err)
+		assert.Equal(t, filepath.Base(path), string(data))
+	}
+}
+
+func TestDownloadLibraries_ChecksumMismatch(t *testing.T) {
+	meta := metaHandler()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/maven/org/ow2/asm/asm/9.7.1/asm-9.7.1.jar" {
+			w.Write([]byte("tampered-.jar"))
+			return
+		}
+		meta.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	err := New("1.18.2", WithBaseURL(server.URL)).DownloadLibraries(context.Background(), t.TempDir(), nil)
+
+	var checksumErr *jarchive.ChecksumError
+	assert.ErrorAs(t, err, &checksumErr)
+	assert.Contains(t, err.Error(), "org.ow2.asm:asm:9.7.1")
+}
+
+func TestDownloadLibraries_UserAgent(t *testing.T) {
+	meta := metaHandler()
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		meta.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	err := New("1.18.2", WithBaseURL(server.URL), WithUserAgent("my-panel/2.0")).DownloadLibraries(context.Background(), t.TempDir(), nil)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, userAgents)
+	for _, userAgent := range userAgents {
+		assert.Equal(t, "my-panel/2.0", userAgent)
+	}
+}
+
+func TestDownloadLibraries_PathTraversal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/versions/loader/1.18.2/0.16.10/server/json" {
+			json.NewEncoder(w).Encode(map[string]any{"libraries": []map[string]any{
+				{"name": "g:a:../../../../etc/x", "url": "http://" + r.Host + "/maven/"},
+			}})
+			return
+		}
+		metaHandler().ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	err := New("1.18.2", WithBaseURL(server.URL)).DownloadLibraries(context.Background(), filepath.Join(dir, "server"), nil)
+
+	assert.ErrorContains(t, err, "invalid Maven coordinates")
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
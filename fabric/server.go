@@ -0,0 +1,151 @@
+package fabric
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/vanilla"
+)
+
+// serverDir is where the server launcher keeps the vanilla server jar and
+// its launch jar, relative to the server directory.
+const serverDir = ".fabric/server"
+
+// PrepareServer stages everything the Fabric server launcher needs in dir so
+// that its first start works without network access, and returns the path of
+// the launcher jar:
+//
+//   - the libraries of the server profile, as with DownloadLibraries;
+//   - the vanilla server jar, resolved with the vanilla provider, as
+//     dir/.fabric/server/<game>-server.jar;
+//   - the launch jar the launcher would otherwise build, as
+//     dir/.fabric/server/fabric-loader-server-<loader>-minecraft-<game>.jar;
+//   - the launcher itself, under the file name Resolve reports.
+//
+// The vanilla provider shares the HTTP client, cache, offline mode, retry
+// policy and User-Agent of c; WithVanillaOptions adds to them. A nil d
+// downloads with the HTTP client and User-Agent of c.
+func (c *Config) PrepareServer(ctx context.Context, dir string, d *jarchive.Downloader) (string, error) {
+	loaderVersion, profile, err := c.getServerProfile(ctx)
+	if err != nil {
+		return "", err
+	}
+	if profile.MainClass == "" {
+		return "", fmt.Errorf("server profile for %s with loader %s has no main class", c.Version, loaderVersion)
+	}
+	libraries, err := c.libraries(loaderVersion, profile)
+	if err != nil {
+		return "", err
+	}
+
+	// Pin the loader so the launcher matches the profile even if Fabric Meta
+	// offers a newer one in the meantime.
+	launcherConfig := *c
+	launcherConfig.LoaderVersion = loaderVersion
+	launcher, err := launcherConfig.ResolveContext(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	opts := append([]vanilla.Option{
+		vanilla.WithHTTPClient(c.client.HTTP),
+		vanilla.WithCache(c.client.Cache, c.client.TTL),
+		vanilla.WithOffline(c.client.Offline),
+		vanilla.WithRetryPolicy(c.client.Retry),
+		vanilla.WithUserAgent(c.client.UserAgent),
+	}, c.vanillaOptions...)
+	server, err := vanilla.New(c.Version, opts...).ResolveContext(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve vanilla server %s: %w", c.Version, err)
+	}
+
+	d = c.downloader(d)
+	if err := downloadLibraries(ctx, dir, d, libraries); err != nil {
+		return "", err
+	}
+
+	dataDir := filepath.Join(dir, filepath.FromSlash(serverDir))
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return "", err
+	}
+	serverJar := filepath.Join(dataDir, fmt.Sprintf("%s-server.jar", c.Version))
+	if err := d.Download(ctx, server, serverJar); err != nil {
+		return "", fmt.Errorf("failed to download vanilla server %s: %w", c.Version, err)
+	}
+
+	launchJar := filepath.Join(dataDir, fmt.Sprintf("fabric-loader-server-%s-minecraft-%s.jar", loaderVersion, c.Version))
+	if err := writeLaunchJar(launchJar, profile.MainClass, libraries); err != nil {
+		return "", fmt.Errorf("failed to write launch jar: %w", err)
+	}
+
+	launcherJar := filepath.Join(dir, launcher.FileName)
+	if err := d.Download(ctx, launcher, launcherJar); err != nil {
+		return "", fmt.Errorf("failed to download server launcher: %w", err)
+	}
+
+	return launcherJar, nil
+}
+
+// writeLaunchJar writes a jar holding only a manifest that starts mainClass
+// with libraries on the class path. Class path entries are relative to the
+// launch jar, which lives in dir/.fabric/server next to dir/libraries.
+func writeLaunchJar(dest, mainClass string, libraries []Library) error {
+	classPath := make([]string, 0, len(libraries))
+	for _, lib := range libraries {
+		entry := &url.URL{Path: "../../libraries/" + lib.Path}
+		classPath = append(classPath, entry.EscapedPath())
+	}
+
+	var manifest strings.Builder
+	writeManifestAttribute(&manifest, "Manifest-Version", "1.0")
+	writeManifestAttribute(&manifest, "Main-Class", mainClass)
+	writeManifestAttribute(&manifest, "Class-Path", strings.Join(classPath, " "))
+	manifest.WriteString("\r\n")
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := zip.NewWriter(tmp)
+	w, err := zw.Create("META-INF/MANIFEST.MF")
+	if err == nil {
+		_, err = w.Write([]byte(manifest.String()))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err := errors.Join(err, tmp.Close()); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
+
+// writeManifestAttribute writes a JAR manifest attribute, wrapping it into
+// continuation lines so that no line exceeds 72 bytes.
+func writeManifestAttribute(b *strings.Builder, name, value string) {
+	line := name + ": " + value
+	width := 72
+	for len(line) > width {
+		n := width
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		b.WriteString(line[:n])
+		b.WriteString("\r\n ")
+		line = line[n:]
+		width = 71 // the leading space counts
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
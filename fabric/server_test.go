@@ -0,0 +1,146 @@
+package fabric
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ciathefed/jarchive/vanilla"
+	"github.com/stretchr/testify/assert"
+)
+
+const vanillaServerJar = "vanilla-server-1.18.2"
+
+// mojangHandler serves a version manifest with 1.18.2 and its server jar.
+func mojangHandler(w http.ResponseWriter, r *http.Request) {
+	base := "http://" + r.Host + "/mojang/"
+	switch r.URL.Path {
+	case "/mojang/version_manifest_v2.json":
+		json.NewEncoder(w).Encode(map[string]any{
+			"latest":   map[string]any{"release": "1.18.2"},
+			"versions": []map[string]any{{"id": "1.18.2", "type": "release", "url": base + "1.18.2.json"}},
+		})
+	case "/mojang/1.18.2.json":
+		json.NewEncoder(w).Encode(map[string]any{
+			"downloads": map[string]any{
+				"server": map[string]any{
+					"url":  base + "server.jar",
+					"sha1": checksum(sha1.New(), vanillaServerJar),
+					"size": len(vanillaServerJar),
+				},
+			},
+		})
+	case "/mojang/server.jar":
+		w.Write([]byte(vanillaServerJar))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestPrepareServer_Success(t *testing.T) {
+	meta := metaHandler()
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		meta.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	config := New("1.18.2",
+		WithBaseURL(server.URL),
+		WithUserAgent("my-panel/2.0"),
+		WithVanillaOptions(vanilla.WithVersionManifestURL(server.URL+"/mojang/version_manifest_v2.json")),
+	)
+	launcher, err := config.PrepareServer(context.Background(), dir, nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fabric-server-mc.1.18.2-loader.0.16.10-launcher.1.0.1.jar"), launcher)
+	info, err := os.Stat(launcher)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1024), info.Size())
+
+	data, err := os.ReadFile(filepath.Join(dir, "libraries", "org", "ow2", "asm", "asm", "9.7.1", "asm-9.7.1.jar"))
+	assert.NoError(t, err)
+	assert.Equal(t, "asm-9.7.1.jar", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, ".fabric", "server", "1.18.2-server.jar"))
+	assert.NoError(t, err)
+	assert.Equal(t, vanillaServerJar, string(data))
+
+	manifest := readManifest(t, filepath.Join(dir, ".fabric", "server", "fabric-loader-server-0.16.10-minecraft-1.18.2.jar"))
+	for _, line := range strings.Split(manifest, "\r\n") {
+		assert.LessOrEqual(t, len(line), 72, line)
+	}
+	manifest = strings.ReplaceAll(manifest, "\r\n ", "")
+	assert.Contains(t, manifest, "Main-Class: net.fabricmc.loader.impl.launch.knot.KnotServer\r\n")
+	assert.Contains(t, manifest, "Class-Path: "+strings.Join([]string{
+		"../../libraries/net/fabricmc/fabric-loader/0.16.10/fabric-loader-0.16.10.jar",
+		"../../libraries/org/ow2/asm/asm/9.7.1/asm-9.7.1.jar",
+		"../../libraries/net/fabricmc/intermediary/1.18.2/intermediary-1.18.2.jar",
+		"../../libraries/net/fabricmc/sponge-mixin/0.15.4+mixin.0.8.7/sponge-mixin-0.15.4+mixin.0.8.7.jar",
+	}, " ")+"\r\n")
+
+	// Every class path entry resolves to a downloaded library.
+	for _, entry := range strings.Fields(strings.TrimPrefix(manifest[strings.Index(manifest, "Class-Path: "):], "Class-Path: ")) {
+		_, err := os.Stat(filepath.Join(dir, ".fabric", "server", filepath.FromSlash(entry)))
+		assert.NoError(t, err, entry)
+	}
+
+	for _, userAgent := range userAgents {
+		assert.Equal(t, "my-panel/2.0", userAgent)
+	}
+}
+
+func TestPrepareServer_NoVanillaServer(t *testing.T) {
+	server := newMetaServer(t)
+	dir := t.TempDir()
+
+	config := New("1.18.2", WithBaseURL(server.URL), noRetry,
+		WithVanillaOptions(vanilla.WithVersionManifestURL(server.URL+"/mojang/missing.json")))
+	_, err := config.PrepareServer(context.Background(), dir, nil)
+
+	assert.ErrorContains(t, err, "failed to resolve vanilla server 1.18.2")
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+func TestWriteManifestAttribute(t *testing.T) {
+	var b strings.Builder
+	writeManifestAttribute(&b, "Class-Path", strings.Repeat("a", 200))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert.Len(t, lines, 3)
+	assert.Len(t, lines[0], 72)
+	assert.Len(t, lines[1], 72)
+	assert.True(t, strings.HasPrefix(lines[1], " "))
+	assert.Equal(t, "Class-Path: "+strings.Repeat("a", 200), strings.ReplaceAll(strings.Join(lines, "\r\n"), "\r\n ", ""))
+}
+
+// readManifest returns the manifest of the jar at path.
+func readManifest(t *testing.T, path string) string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer r.Close()
+
+	f, err := r.Open("META-INF/MANIFEST.MF")
+	if !assert.NoError(t, err) {
+		return ""
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	return string(data)
+}
@@ -1,88 +1,305 @@
 package forge
 
 import (
-	"encoding/json"
+	"cmp"
+	"context"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
+	"github.com/ciathefed/jarchive/internal/utils"
+)
+
+const providerName = "forge"
+
+const (
+	defaultPromotionsURL = "https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json"
+	defaultBaseURL       = "https://maven.minecraftforge.net/net/minecraftforge/forge"
+)
+
+// Selection picks which promotion resolves the Forge version when
+// Config.ForgeVersion is empty.
+type Selection string
+
+const (
+	// SelectLatest resolves the newest Forge build promoted for the
+	// Minecraft version.
+	SelectLatest Selection = "latest"
+	// SelectRecommended resolves the build Forge recommends for production
+	// and fails when the Minecraft version has none.
+	SelectRecommended Selection = "recommended"
+	// SelectRecommendedOrLatest resolves the recommended build, or the latest
+	// one when the Minecraft version has no recommended build.
+	SelectRecommendedOrLatest Selection = "recommended-or-latest"
 )
 
+// Promotion holds the Forge versions promoted for a Minecraft version. Either
+// may be empty; many versions only have a latest build.
+type Promotion struct {
+	Version     string `json:"version"` // Minecraft version
+	Recommended string `json:"recommended,omitempty"`
+	Latest      string `json:"latest,omitempty"`
+}
+
 var (
-	promotionsSlimURL = "https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json"
-	baseURL           = "https://maven.minecraftforge.net/net/minecraftforge/forge"
+	_ jarchive.Jarchive      = (*Config)(nil)
+	_ jarchive.VersionLister = (*Config)(nil)
 )
 
 type Config struct {
-	Version      string // Minecraft version
-	ForgeVersion string // Forge version (optional)
+	Version string // Minecraft version
+
+	// ForgeVersion pins the Forge version to resolve. When empty, every call
+	// resolves the promotion picked by the selection for Version and leaves
+	// the field empty.
+	ForgeVersion string
+
+	selection     Selection
+	client        *httputil.Client
+	baseURL       string
+	promotionsURL string
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHTTPClient is the provider-level form of jarchive.WithHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return withOptions(jarchive.WithHTTPClient(client))
+}
+
+// WithCache is the provider-level form of jarchive.WithCache.
+func WithCache(store cache.Cache, ttl time.Duration) Option {
+	return withOptions(jarchive.WithCache(store, ttl))
+}
+
+// WithOffline is the provider-level form of jarchive.WithOffline.
+func WithOffline(offline bool) Option {
+	return withOptions(jarchive.WithOffline(offline))
+}
+
+// WithRetryPolicy is the provider-level form of jarchive.WithRetryPolicy.
+func WithRetryPolicy(policy jarchive.RetryPolicy) Option {
+	return withOptions(jarchive.WithRetryPolicy(policy))
 }
 
-func New(version string) *Config {
-	return &Config{
-		Version: version,
+// WithUserAgent is the provider-level form of jarchive.WithUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return withOptions(jarchive.WithUserAgent(userAgent))
+}
+
+// WithSelection sets which promotion resolves the Forge version when
+// ForgeVersion is empty. The default is SelectLatest.
+func WithSelection(selection Selection) Option {
+	return func(c *Config) {
+		c.selection = selection
+	}
+}
+
+// WithBaseURL sets the Forge Maven repository base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) {
+		c.baseURL = url
+	}
+}
+
+// WithPromotionsURL sets the URL of the Forge promotions_slim.json file.
+func WithPromotionsURL(url string) Option {
+	return func(c *Config) {
+		c.promotionsURL = url
+	}
+}
+
+func New(version string, opts ...Option) *Config {
+	c := &Config{
+		Version:       version,
+		selection:     SelectLatest,
+		client:        httputil.NewClient(),
+		baseURL:       defaultBaseURL,
+		promotionsURL: defaultPromotionsURL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func init() {
+	jarchive.Register(providerName, func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+		return New(version, withOptions(opts...)), nil
+	})
+}
+
+// withOptions applies the provider-independent jarchive options.
+func withOptions(opts ...jarchive.Option) Option {
+	return func(c *Config) {
+		c.client.Apply(opts...)
 	}
 }
 
 // Mirror fetches the download URL for the Forge installer.
 func (c *Config) Mirror() (string, error) {
-	// If no Forge version is specified, fetch the latest one
-	if c.ForgeVersion == "" {
-		latestForgeVersion, err := getLatestForgeVersion(c.Version)
+	return c.MirrorContext(context.Background())
+}
+
+// MirrorContext is like Mirror but honors the deadline and cancellation of ctx.
+func (c *Config) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := c.ResolveContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return artifact.URL, nil
+}
+
+// Resolve returns the Forge installer artifact.
+func (c *Config) Resolve() (*jarchive.Artifact, error) {
+	return c.ResolveContext(context.Background())
+}
+
+// ResolveContext is like Resolve but honors the deadline and cancellation of ctx.
+func (c *Config) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	// If no Forge version is specified, fetch the selected promotion
+	forgeVersion := c.ForgeVersion
+	if forgeVersion == "" {
+		promotedVersion, err := c.getPromotedVersion(ctx)
 		if err != nil {
-			return "", fmt.Errorf("failed to get latest Forge version: %w", err)
+			return nil, fmt.Errorf("failed to get %s Forge version: %w", c.selection, err)
 		}
-		c.ForgeVersion = latestForgeVersion
+		forgeVersion = promotedVersion
 	}
 
 	// Construct the Maven URL for the Forge installer
-	mavenURL := fmt.Sprintf(
-		"%s/%s-%s/forge-%s-%s-installer.jar",
-		baseURL,
-		c.Version,
-		c.ForgeVersion,
-		c.Version,
-		c.ForgeVersion,
-	)
+	fileName := fmt.Sprintf("forge-%s-%s-installer.jar", c.Version, forgeVersion)
+	mavenURL := fmt.Sprintf("%s/%s-%s/%s", c.baseURL, c.Version, forgeVersion, fileName)
 
 	// Verify the URL by making a HEAD request
-	resp, err := http.Head(mavenURL)
+	resp, err := c.client.Head(ctx, mavenURL)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: Forge %s for Minecraft %s: %w", jarchive.ErrBuildNotFound, forgeVersion, c.Version, err)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to verify URL: %w", err)
+		return nil, fmt.Errorf("failed to verify URL: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid URL: status code %d", resp.StatusCode)
+	artifact := &jarchive.Artifact{
+		URL:      mavenURL,
+		FileName: fileName,
+		Provider: providerName,
+		Kind:     jarchive.KindInstaller,
+		Version:  c.Version,
+		Build:    forgeVersion,
+	}
+	if resp.ContentLength > 0 {
+		artifact.Size = resp.ContentLength
 	}
 
-	return mavenURL, nil
+	return artifact, nil
 }
 
-// getLatestForgeVersion fetches the latest Forge version for a specific Minecraft version.
-func getLatestForgeVersion(mcVersion string) (string, error) {
-	// Fetch the list of Forge versions for the specified Minecraft version
-	resp, err := http.Get(promotionsSlimURL)
+// getPromotedVersion returns the Forge version the selection picks from the
+// promotions for the configured Minecraft version.
+func (c *Config) getPromotedVersion(ctx context.Context) (string, error) {
+	promos, err := c.getPromotions(ctx)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid response: status code %d", resp.StatusCode)
+	recommended := promos[c.Version+"-recommended"]
+	latest := promos[c.Version+"-latest"]
+
+	var forgeVersion string
+	switch c.selection {
+	case SelectLatest:
+		forgeVersion = latest
+	case SelectRecommended:
+		forgeVersion = recommended
+	case SelectRecommendedOrLatest:
+		forgeVersion = cmp.Or(recommended, latest)
+	default:
+		return "", fmt.Errorf("unknown Forge selection %q", c.selection)
 	}
 
-	var promotions struct {
-		Promos map[string]string `json:"promos"`
+	if forgeVersion == "" {
+		// Without either promotion Forge does not support the version at all;
+		// otherwise only the selected build is missing.
+		if recommended == "" && latest == "" {
+			return "", fmt.Errorf("%w: no Forge version found for Minecraft version %s", jarchive.ErrVersionNotFound, c.Version)
+		}
+		return "", fmt.Errorf("%w: no %s Forge build for Minecraft version %s", jarchive.ErrBuildNotFound, c.selection, c.Version)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&promotions); err != nil {
-		return "", err
+
+	return forgeVersion, nil
+}
+
+// ListVersions returns the Minecraft versions that have a Forge promotion.
+func (c *Config) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	promotions, err := c.ListPromotions(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Find the latest Forge version for the specified Minecraft version
-	key := fmt.Sprintf("%s-latest", mcVersion)
-	forgeVersion, ok := promotions.Promos[key]
-	if !ok {
-		return "", fmt.Errorf("no Forge version found for Minecraft version %s", mcVersion)
+	versions := make([]jarchive.Version, 0, len(promotions))
+	for _, p := range promotions {
+		versions = append(versions, jarchive.Version{ID: p.Version})
 	}
 
-	return forgeVersion, nil
+	return versions, nil
+}
+
+// ListPromotions returns the recommended and latest Forge versions of every
+// Minecraft version with a promotion, newest Minecraft version first.
+func (c *Config) ListPromotions(ctx context.Context) ([]Promotion, error) {
+	promos, err := c.getPromotions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[string]*Promotion)
+	for key, forgeVersion := range promos {
+		// Keys look like "1.20.1-latest" or "1.20.1-recommended"
+		i := strings.LastIndex(key, "-")
+		if i < 0 {
+			continue
+		}
+		version := key[:i]
+		p, ok := byVersion[version]
+		if !ok {
+			p = &Promotion{Version: version}
+			byVersion[version] = p
+		}
+		switch key[i+1:] {
+		case "recommended":
+			p.Recommended = forgeVersion
+		case "latest":
+			p.Latest = forgeVersion
+		}
+	}
+
+	promotions := make([]Promotion, 0, len(byVersion))
+	for _, p := range byVersion {
+		promotions = append(promotions, *p)
+	}
+	slices.SortFunc(promotions, func(a, b Promotion) int {
+		return utils.CompareVersions(b.Version, a.Version)
+	})
+
+	return promotions, nil
+}
+
+// getPromotions fetches the promotions_slim.json map of promotion keys to Forge versions.
+func (c *Config) getPromotions(ctx context.Context) (map[string]string, error) {
+	var promotions struct {
+		Promos map[string]string `json:"promos"`
+	}
+	if err := c.client.GetJSON(ctx, c.promotionsURL, &promotions); err != nil {
+		return nil, err
+	}
+
+	return promotions.Promos, nil
 }
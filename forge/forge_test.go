@@ -1,18 +1,55 @@
 package forge
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
 	"github.com/stretchr/testify/assert"
 )
 
+// noRetry keeps tests of failing upstreams fast.
+var noRetry = WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 1})
+
 func TestNew(t *testing.T) {
 	config := New("1.18.2")
 	assert.Equal(t, "1.18.2", config.Version)
 	assert.Equal(t, "", config.ForgeVersion)
+	assert.Equal(t, http.DefaultClient, config.client.HTTP)
+	assert.Nil(t, config.client.Cache)
+	assert.Equal(t, defaultBaseURL, config.baseURL)
+	assert.Equal(t, defaultPromotionsURL, config.promotionsURL)
+	assert.Equal(t, SelectLatest, config.selection)
+}
+
+func TestNew_WithOptions(t *testing.T) {
+	client := &http.Client{}
+	store := cache.NewMemory()
+	config := New("1.18.2", WithHTTPClient(client), WithCache(store, time.Minute), WithOffline(true), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}), WithBaseURL("https://maven.example.com/forge"), WithPromotionsURL("https://files.example.com/promotions_slim.json"), WithSelection(SelectRecommended))
+	assert.Same(t, client, config.client.HTTP)
+	assert.Same(t, store, config.client.Cache)
+	assert.Equal(t, time.Minute, config.client.TTL)
+	assert.Equal(t, "https://maven.example.com/forge", config.baseURL)
+	assert.Equal(t, "https://files.example.com/promotions_slim.json", config.promotionsURL)
+	assert.True(t, config.client.Offline)
+	assert.Equal(t, 5, config.client.Retry.MaxAttempts)
+	assert.Equal(t, SelectRecommended, config.selection)
+}
+
+func TestRegistered(t *testing.T) {
+	client := &http.Client{}
+	provider, err := jarchive.New("forge", "1.18.2", jarchive.WithHTTPClient(client))
+
+	assert.NoError(t, err)
+	config, ok := provider.(*Config)
+	assert.True(t, ok)
+	assert.Equal(t, "1.18.2", config.Version)
+	assert.Same(t, client, config.client.HTTP)
 }
 
 func TestMirror_Success(t *testing.T) {
@@ -32,15 +69,13 @@ func TestMirror_Success(t *testing.T) {
 	}))
 	defer mavenServer.Close()
 
-	promotionsSlimURL = promotionsServer.URL
-	baseURL = mavenServer.URL + "/net/minecraftforge/forge"
-
-	config := New("1.18.2")
+	config := New("1.18.2", WithPromotionsURL(promotionsServer.URL), WithBaseURL(mavenServer.URL+"/net/minecraftforge/forge"))
 	mirrorURL, err := config.Mirror()
 
 	assert.NoError(t, err)
 	expectedURL := mavenServer.URL + "/net/minecraftforge/forge/1.18.2-40.1.0/forge-1.18.2-40.1.0-installer.jar"
 	assert.Equal(t, expectedURL, mirrorURL)
+	assert.Equal(t, "", config.ForgeVersion)
 }
 
 func TestMirror_WithForgeVersion(t *testing.T) {
@@ -49,9 +84,7 @@ func TestMirror_WithForgeVersion(t *testing.T) {
 	}))
 	defer mavenServer.Close()
 
-	baseURL = mavenServer.URL + "/net/minecraftforge/forge"
-
-	config := New("1.18.2")
+	config := New("1.18.2", WithBaseURL(mavenServer.URL+"/net/minecraftforge/forge"))
 	config.ForgeVersion = "40.1.0"
 	mirrorURL, err := config.Mirror()
 
@@ -72,12 +105,11 @@ func TestMirror_InvalidMinecraftVersion(t *testing.T) {
 	}))
 	defer promotionsServer.Close()
 
-	promotionsSlimURL = promotionsServer.URL
-
-	config := New("invalid-version")
+	config := New("invalid-version", WithPromotionsURL(promotionsServer.URL))
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
 	assert.Contains(t, err.Error(), "no Forge version found for Minecraft version")
 }
 
@@ -98,17 +130,14 @@ func TestMirror_InvalidMavenURL(t *testing.T) {
 	}))
 	defer mavenServer.Close()
 
-	promotionsSlimURL = promotionsServer.URL
-	baseURL = mavenServer.URL + "/net/minecraftforge/forge"
-
-	config := New("1.18.2")
+	config := New("1.18.2", WithPromotionsURL(promotionsServer.URL), WithBaseURL(mavenServer.URL+"/net/minecraftforge/forge"))
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid URL: status code 404")
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
 }
 
-func TestGetLatestForgeVersion_Success(t *testing.T) {
+func TestGetPromotedVersion_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := map[string]any{
 			"promos": map[string]string{
@@ -120,15 +149,14 @@ func TestGetLatestForgeVersion_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	promotionsSlimURL = server.URL
-
-	forgeVersion, err := getLatestForgeVersion("1.18.2")
+	config := New("1.18.2", WithPromotionsURL(server.URL))
+	forgeVersion, err := config.getPromotedVersion(context.Background())
 
 	assert.NoError(t, err)
 	assert.Equal(t, "40.1.0", forgeVersion)
 }
 
-func TestGetLatestForgeVersion_InvalidMinecraftVersion(t *testing.T) {
+func TestGetPromotedVersion_InvalidMinecraftVersion(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := map[string]any{
 			"promos": map[string]string{
@@ -140,24 +168,183 @@ func TestGetLatestForgeVersion_InvalidMinecraftVersion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	promotionsSlimURL = server.URL
-
-	_, err := getLatestForgeVersion("invalid-version")
+	config := New("invalid-version", WithPromotionsURL(server.URL))
+	_, err := config.getPromotedVersion(context.Background())
 
 	assert.Error(t, err)
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
 	assert.Contains(t, err.Error(), "no Forge version found for Minecraft version")
 }
 
-func TestGetLatestForgeVersion_InvalidResponse(t *testing.T) {
+func TestGetPromotedVersion_InvalidResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
 	}))
 	defer server.Close()
 
-	promotionsSlimURL = server.URL
+	config := New("1.18.2", WithPromotionsURL(server.URL), noRetry)
+	_, err := config.getPromotedVersion(context.Background())
+
+	assert.Error(t, err)
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusInternalServerError, upstreamErr.StatusCode)
+}
+
+func TestMirrorContext_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	_, err := getLatestForgeVersion("1.18.2")
+	config := New("1.18.2", WithPromotionsURL(server.URL), WithBaseURL(server.URL+"/net/minecraftforge/forge"))
+	_, err := config.MirrorContext(ctx)
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid response: status code 500")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolve_Success(t *testing.T) {
+	mavenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "2048")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mavenServer.Close()
+
+	config := New("1.18.2", WithBaseURL(mavenServer.URL+"/net/minecraftforge/forge"))
+	config.ForgeVersion = "40.1.0"
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, mavenServer.URL+"/net/minecraftforge/forge/1.18.2-40.1.0/forge-1.18.2-40.1.0-installer.jar", artifact.URL)
+	assert.Equal(t, "forge-1.18.2-40.1.0-installer.jar", artifact.FileName)
+	assert.Equal(t, "forge", artifact.Provider)
+	assert.Equal(t, jarchive.KindInstaller, artifact.Kind)
+	assert.Equal(t, "1.18.2", artifact.Version)
+	assert.Equal(t, "40.1.0", artifact.Build)
+	assert.Equal(t, int64(2048), artifact.Size)
+}
+
+func TestListVersions_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"promos": map[string]string{
+				"1.9-latest":         "12.16.0.1865",
+				"1.18.2-latest":      "40.1.0",
+				"1.18.2-recommended": "40.0.0",
+				"1.10-latest":        "12.18.0.2000",
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithPromotionsURL(server.URL))
+	versions, err := config.ListVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Version{{ID: "1.18.2"}, {ID: "1.10"}, {ID: "1.9"}}, versions)
+}
+
+func TestMirror_CachedPromotions(t *testing.T) {
+	var requests int
+	promotionsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		response := map[string]any{
+			"promos": map[string]string{
+				"1.18.2-latest": "40.1.0",
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer promotionsServer.Close()
+
+	mavenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mavenServer.Close()
+
+	store := cache.NewMemory()
+	for i := 0; i < 2; i++ {
+		config := New("1.18.2", WithPromotionsURL(promotionsServer.URL), WithBaseURL(mavenServer.URL), WithCache(store, time.Hour))
+		_, err := config.Mirror()
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, requests)
+}
+
+func TestGetPromotedVersion_Selection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"promos": map[string]string{
+				"1.18.2-latest":      "40.1.0",
+				"1.18.2-recommended": "40.0.0",
+				"1.19-latest":        "41.0.1",
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		version   string
+		selection Selection
+		want      string
+	}{
+		{"1.18.2", SelectLatest, "40.1.0"},
+		{"1.18.2", SelectRecommended, "40.0.0"},
+		{"1.18.2", SelectRecommendedOrLatest, "40.0.0"},
+		{"1.19", SelectLatest, "41.0.1"},
+		{"1.19", SelectRecommendedOrLatest, "41.0.1"},
+	}
+	for _, tt := range tests {
+		config := New(tt.version, WithPromotionsURL(server.URL), WithSelection(tt.selection))
+		forgeVersion, err := config.getPromotedVersion(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, forgeVersion, "%s %s", tt.version, tt.selection)
+	}
+
+	_, err := New("1.19", WithPromotionsURL(server.URL), WithSelection(SelectRecommended)).getPromotedVersion(context.Background())
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.NotErrorIs(t, err, jarchive.ErrVersionNotFound)
+	assert.ErrorContains(t, err, "no recommended Forge build for Minecraft version 1.19")
+
+	_, err = New("1.7", WithPromotionsURL(server.URL), WithSelection(SelectRecommended)).getPromotedVersion(context.Background())
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+
+	_, err = New("1.19", WithPromotionsURL(server.URL), WithSelection("newest")).getPromotedVersion(context.Background())
+	assert.ErrorContains(t, err, `unknown Forge selection "newest"`)
+}
+
+func TestListPromotions_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"promos": map[string]string{
+				"1.9-latest":         "12.16.0.1865",
+				"1.18.2-latest":      "40.1.0",
+				"1.18.2-recommended": "40.0.0",
+				"1.10-recommended":   "12.18.0.2000",
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	promotions, err := New("1.18.2", WithPromotionsURL(server.URL)).ListPromotions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Promotion{
+		{Version: "1.18.2", Recommended: "40.0.0", Latest: "40.1.0"},
+		{Version: "1.10", Recommended: "12.18.0.2000"},
+		{Version: "1.9", Latest: "12.16.0.1865"},
+	}, promotions)
 }
@@ -0,0 +1,328 @@
+package httputil
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+)
+
+// DefaultUserAgent is jarchive.DefaultUserAgent.
+const DefaultUserAgent = jarchive.DefaultUserAgent
+
+// Client sends the requests of a provider.
+type Client struct {
+	HTTP      *http.Client
+	UserAgent string // sent with every request when set
+
+	// Cache, if set, stores GET responses made through GetBytes and GetJSON.
+	// Entries younger than TTL are used without contacting the server; older
+	// ones are revalidated with a conditional request.
+	Cache cache.Cache
+	TTL   time.Duration
+
+	// Offline answers GetBytes, GetJSON and Head from the cache alone and
+	// fails with jarchive.ErrOffline on a miss.
+	Offline bool
+
+	// Retry controls how requests failing with a transient network error
+	// or a temporary upstream status are retried.
+	Retry jarchive.RetryPolicy
+}
+
+// NewClient returns a Client that uses http.DefaultClient, DefaultUserAgent,
+// jarchive.DefaultRetryPolicy and no cache.
+func NewClient() *Client {
+	return &Client{
+		HTTP:      http.DefaultClient,
+		UserAgent: DefaultUserAgent,
+		Retry:     jarchive.DefaultRetryPolicy,
+	}
+}
+
+// Apply sets the provider-independent jarchive options on c. Settings the
+// options leave alone keep their current values, so options can be applied
+// one at a time. A nil HTTP client selects http.DefaultClient and an empty
+// User-Agent selects DefaultUserAgent.
+func (c *Client) Apply(opts ...jarchive.Option) {
+	retry := c.Retry
+	o := &jarchive.Options{
+		HTTPClient:  c.HTTP,
+		Cache:       c.Cache,
+		CacheTTL:    c.TTL,
+		Offline:     c.Offline,
+		RetryPolicy: &retry,
+		UserAgent:   c.UserAgent,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	c.HTTP = cmp.Or(o.HTTPClient, http.DefaultClient)
+	c.Cache = o.Cache
+	c.TTL = o.CacheTTL
+	c.Offline = o.Offline
+	if o.RetryPolicy != nil {
+		c.Retry = *o.RetryPolicy
+	}
+	c.UserAgent = cmp.Or(o.UserAgent, DefaultUserAgent)
+}
+
+// Head sends a HEAD request for url. Error statuses are returned as a
+// *jarchive.UpstreamError. Successful responses are recorded in the cache so
+// that Head keeps working offline.
+func (c *Client) Head(ctx context.Context, url string) (*http.Response, error) {
+	key := http.MethodHead + " " + url
+
+	if c.Offline {
+		if c.Cache != nil {
+			if entry, ok := c.Cache.Get(key); ok {
+				resp := &http.Response{
+					Status:        http.StatusText(http.StatusOK),
+					StatusCode:    http.StatusOK,
+					Header:        entry.Header,
+					Body:          http.NoBody,
+					ContentLength: -1,
+				}
+				if n, err := strconv.ParseInt(entry.Header.Get("Content-Length"), 10, 64); err == nil {
+					resp.ContentLength = n
+				}
+				return resp, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: HEAD %s", jarchive.ErrOffline, url)
+	}
+
+	resp, err := c.do(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Cache != nil {
+		header := resp.Header.Clone()
+		if resp.ContentLength >= 0 {
+			header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+		}
+		c.Cache.Set(key, &cache.Entry{Header: header, StoredAt: time.Now()})
+	}
+
+	return resp, nil
+}
+
+// GetBytes returns the body of a GET request for url, consulting the cache
+// first when one is configured.
+func (c *Client) GetBytes(ctx context.Context, url string) ([]byte, error) {
+	var cached *cache.Entry
+	if c.Cache != nil {
+		if entry, ok := c.Cache.Get(url); ok {
+			if c.Offline || time.Since(entry.StoredAt) < c.TTL {
+				return entry.Body, nil
+			}
+			cached = entry
+		}
+	}
+	if c.Offline {
+		return nil, fmt.Errorf("%w: GET %s", jarchive.ErrOffline, url)
+	}
+
+	header := make(http.Header)
+	if cached != nil {
+		if cached.ETag != "" {
+			header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, url, header)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.StoredAt = time.Now()
+		c.Cache.Set(url, cached)
+		return cached.Body, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Cache != nil {
+		c.Cache.Set(url, &cache.Entry{
+			Body:         body,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			StoredAt:     time.Now(),
+		})
+	}
+
+	return body, nil
+}
+
+// GetJSON decodes the body returned by GetBytes into v.
+func (c *Client) GetJSON(ctx context.Context, url string, v any) error {
+	body, err := c.GetBytes(ctx, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// HasStatus reports whether err is a *jarchive.UpstreamError with one of the
+// given status codes.
+func HasStatus(err error, codes ...int) bool {
+	var upstreamErr *jarchive.UpstreamError
+	if !errors.As(err, &upstreamErr) {
+		return false
+	}
+	for _, code := range codes {
+		if upstreamErr.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an upstream 404.
+func IsNotFound(err error) bool {
+	return HasStatus(err, http.StatusNotFound)
+}
+
+// do sends a request, retrying it according to c.Retry when it is idempotent
+// and fails with a transient network error or a temporary upstream status.
+// GET bodies are read in full before do returns, so a connection that drops
+// mid-body is retried as well.
+func (c *Client) do(ctx context.Context, method, url string, header http.Header) (*http.Response, error) {
+	idempotent := method == http.MethodGet || method == http.MethodHead
+
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, err := c.send(ctx, method, url, header)
+		if err == nil && method == http.MethodGet {
+			if err = bufferBody(resp); err != nil {
+				resp = nil
+			}
+		}
+		if err == nil || !idempotent || attempt >= c.Retry.MaxAttempts || !retryable(ctx, err) {
+			return resp, err
+		}
+
+		delay := c.Retry.Backoff(attempt)
+		if retryAfter > 0 {
+			if c.Retry.MaxDelay > 0 && retryAfter > c.Retry.MaxDelay {
+				return nil, err
+			}
+			delay = max(delay, retryAfter)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// send sends a single request. Error statuses are returned as a
+// *jarchive.UpstreamError together with the delay requested by the
+// Retry-After header, if any.
+func (c *Client) send(ctx context.Context, method, url string, header http.Header) (*http.Response, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode > 399 {
+		resp.Body.Close()
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), &jarchive.UpstreamError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	return resp, 0, nil
+}
+
+// bufferBody replaces the body of resp with an in-memory copy.
+func bufferBody(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+// retryable reports whether a request that failed with err may succeed when
+// sent again. Every error from http.Client.Do is a net.Error, so only
+// timeouts, dropped or refused connections and temporary DNS failures count;
+// permanent ones such as an unsupported scheme or an untrusted certificate
+// do not.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var upstreamErr *jarchive.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr.Temporary()
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header, given
+// either in seconds or as an HTTP date, or zero if there is none.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
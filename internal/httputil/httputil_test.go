@@ -0,0 +1,366 @@
+package httputil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetJSON_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"name":"paper"}`))
+	}))
+	defer server.Close()
+
+	var data struct {
+		Name string `json:"name"`
+	}
+	err := NewClient().GetJSON(context.Background(), server.URL, &data)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "paper", data.Name)
+}
+
+func TestHead_UpstreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := NewClient().Head(context.Background(), server.URL)
+
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.MethodHead, upstreamErr.Method)
+	assert.Equal(t, server.URL, upstreamErr.URL)
+	assert.Equal(t, http.StatusNotFound, upstreamErr.StatusCode)
+}
+
+func TestHasStatus(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &jarchive.UpstreamError{StatusCode: http.StatusNotFound})
+
+	assert.True(t, IsNotFound(err))
+	assert.True(t, HasStatus(err, http.StatusBadRequest, http.StatusNotFound))
+	assert.False(t, HasStatus(err, http.StatusBadRequest))
+	assert.False(t, IsNotFound(errors.New("boom")))
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestGetBytes_CacheFresh(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte("manifest"))
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Cache: cache.NewMemory(), TTL: time.Hour}
+	for i := 0; i < 3; i++ {
+		body, err := client.GetBytes(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("manifest"), body)
+	}
+
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestGetBytes_CacheRevalidate(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` && r.Header.Get("If-Modified-Since") == "Tue, 02 Jan 2024 03:04:05 GMT" {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Tue, 02 Jan 2024 03:04:05 GMT")
+		w.Write([]byte("manifest"))
+	}))
+	defer server.Close()
+
+	store := cache.NewMemory()
+	client := &Client{HTTP: http.DefaultClient, Cache: store, TTL: 0}
+	for i := 0; i < 2; i++ {
+		body, err := client.GetBytes(context.Background(), server.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("manifest"), body)
+	}
+
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, int32(1), notModified.Load())
+	entry, ok := store.Get(server.URL)
+	assert.True(t, ok)
+	assert.Equal(t, `"v1"`, entry.ETag)
+}
+
+func TestGetBytes_UpstreamErrorNotCached(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	store := cache.NewMemory()
+	client := &Client{HTTP: http.DefaultClient, Cache: store, TTL: time.Hour}
+	_, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.True(t, HasStatus(err, http.StatusBadGateway))
+	_, ok := store.Get(server.URL)
+	assert.False(t, ok)
+}
+
+func TestGetBytes_Offline(t *testing.T) {
+	store := cache.NewMemory()
+	store.Set("https://example.com/cached", &cache.Entry{Body: []byte("stale"), StoredAt: time.Unix(0, 0)})
+	client := &Client{HTTP: http.DefaultClient, Cache: store, Offline: true}
+
+	body, err := client.GetBytes(context.Background(), "https://example.com/cached")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("stale"), body)
+
+	_, err = client.GetBytes(context.Background(), "https://example.com/missing")
+	assert.ErrorIs(t, err, jarchive.ErrOffline)
+}
+
+func TestHead_Offline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	store := cache.NewMemory()
+	online := &Client{HTTP: http.DefaultClient, Cache: store}
+	resp, err := online.Head(context.Background(), server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	server.Close()
+
+	offline := &Client{HTTP: http.DefaultClient, Cache: store, Offline: true}
+	resp, err = offline.Head(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(1024), resp.ContentLength)
+
+	_, err = offline.Head(context.Background(), server.URL+"/missing")
+	assert.ErrorIs(t, err, jarchive.ErrOffline)
+}
+
+func TestDo_RetriesTemporaryStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch requests.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	body, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), body)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestDo_RetriesExhausted(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	_, err := client.Head(context.Background(), server.URL)
+
+	assert.True(t, HasStatus(err, http.StatusServiceUnavailable))
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestDo_NoRetryOnClientError(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	_, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+// countingTransport counts the requests it sends.
+type countingTransport struct {
+	requests atomic.Int32
+	next     http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return t.next.RoundTrip(req)
+}
+
+func TestDo_RetriesTruncatedBody(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Promise more than is sent, then hang up.
+			w.Header().Set("Content-Length", "100")
+			fmt.Fprint(w, "partial")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	body, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ok"), body)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestDo_RetriesRefusedConnection(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	transport := &countingTransport{next: http.DefaultTransport}
+	client := &Client{HTTP: &http.Client{Transport: transport}, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+	_, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	assert.Equal(t, int32(3), transport.requests.Load())
+}
+
+func TestDo_NoRetryOnPermanentError(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	tests := map[string]string{
+		"untrusted certificate": server.URL,
+		"unsupported scheme":    "ftp://example.com/server.jar",
+	}
+	for name, url := range tests {
+		transport := &countingTransport{next: &http.Transport{}}
+		client := &Client{HTTP: &http.Client{Transport: transport}, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}}
+		_, err := client.GetBytes(context.Background(), url)
+
+		assert.Error(t, err, name)
+		assert.Equal(t, int32(1), transport.requests.Load(), name)
+	}
+}
+
+func TestDo_RetryAfterTooLong(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}}
+	_, err := client.GetBytes(context.Background(), server.URL)
+
+	assert.True(t, HasStatus(err, http.StatusTooManyRequests))
+	assert.Equal(t, int32(1), requests.Load())
+}
+
+func TestDo_CanceledDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := &Client{HTTP: http.DefaultClient, Retry: jarchive.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour}}
+	start := time.Now()
+	_, err := client.GetBytes(ctx, server.URL)
+
+	assert.True(t, HasStatus(err, http.StatusServiceUnavailable))
+	assert.Less(t, time.Since(start), time.Minute)
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter("-5"))
+	assert.Equal(t, 120*time.Second, parseRetryAfter("120"))
+	assert.Equal(t, time.Duration(0), parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+
+	delay := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.Greater(t, delay, 59*time.Minute)
+	assert.LessOrEqual(t, delay, time.Hour)
+}
+
+func TestDo_UserAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.UserAgent())
+	}))
+	defer server.Close()
+
+	body, err := NewClient().GetBytes(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultUserAgent, string(body))
+
+	client := NewClient()
+	client.UserAgent = "my-panel/2.0 (ops@example.com)"
+	body, err = client.GetBytes(context.Background(), server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "my-panel/2.0 (ops@example.com)", string(body))
+}
+
+func TestApply(t *testing.T) {
+	httpClient := &http.Client{}
+	store := cache.NewMemory()
+	client := NewClient()
+
+	client.Apply(
+		jarchive.WithHTTPClient(httpClient),
+		jarchive.WithCache(store, time.Minute),
+		jarchive.WithOffline(true),
+		jarchive.WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}),
+		jarchive.WithUserAgent("my-panel/2.0"),
+	)
+	assert.Same(t, httpClient, client.HTTP)
+	assert.Same(t, store, client.Cache)
+	assert.Equal(t, time.Minute, client.TTL)
+	assert.True(t, client.Offline)
+	assert.Equal(t, 5, client.Retry.MaxAttempts)
+	assert.Equal(t, "my-panel/2.0", client.UserAgent)
+
+	// Options applied one at a time leave the other settings alone.
+	client.Apply(jarchive.WithOffline(false))
+	assert.False(t, client.Offline)
+	assert.Same(t, httpClient, client.HTTP)
+	assert.Same(t, store, client.Cache)
+	assert.Equal(t, 5, client.Retry.MaxAttempts)
+	assert.Equal(t, "my-panel/2.0", client.UserAgent)
+
+	client.Apply(jarchive.WithHTTPClient(nil), jarchive.WithUserAgent(""))
+	assert.Equal(t, http.DefaultClient, client.HTTP)
+	assert.Equal(t, DefaultUserAgent, client.UserAgent)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"strings"
 )
 
 func URLJoin(u string, elem ...string) (string, error) {
@@ -14,3 +15,14 @@ func URLJoin(u string, elem ...string) (string, error) {
 	t.Path = path.Join(append([]string{t.Path}, elem...)...)
 	return t.String(), nil
 }
+
+// FileName reduces an upstream-supplied name to its last slash-separated
+// element and reports whether the result is safe to use as a local file
+// name: not empty, "." or "..", and free of path separators.
+func FileName(name string) (string, bool) {
+	name = path.Base(name)
+	if name == "." || name == ".." || name == "/" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+	return name, true
+}
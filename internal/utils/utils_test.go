@@ -29,3 +29,28 @@ func TestURLJoin(t *testing.T) {
 		})
 	}
 }
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		ok       bool
+	}{
+		{"paper-1.21-1.jar", "paper-1.21-1.jar", true},
+		{"../../x.jar", "x.jar", true},
+		{"/v1/objects/abc/paper.jar", "paper.jar", true},
+		{"", "", false},
+		{".", "", false},
+		{"..", "", false},
+		{"a/..", "", false},
+		{"/", "", false},
+		{`..\..\x.jar`, "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := FileName(tt.name)
+		if got != tt.expected || ok != tt.ok {
+			t.Errorf("FileName(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.expected, tt.ok)
+		}
+	}
+}
@@ -1,5 +1,10 @@
 package jarchive
 
+import "context"
+
 type Jarchive interface {
 	Mirror() (string, error)
+	MirrorContext(ctx context.Context) (string, error)
+	Resolve() (*Artifact, error)
+	ResolveContext(ctx context.Context) (*Artifact, error)
 }
@@ -1,84 +1,414 @@
+// Package paper resolves server and proxy jars of PaperMC projects. Importing
+// it registers the paper, folia, velocity and waterfall providers.
 package paper
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
 	"github.com/ciathefed/jarchive/internal/utils"
 )
 
-var baseURL = "https://api.papermc.io/v2/projects/paper"
+// PaperMC projects registered as providers.
+const (
+	ProjectPaper     = "paper"
+	ProjectFolia     = "folia"
+	ProjectVelocity  = "velocity"
+	ProjectWaterfall = "waterfall"
+)
+
+// proxyProjects are the projects that publish proxies rather than servers.
+var proxyProjects = []string{ProjectVelocity, ProjectWaterfall}
+
+const defaultBaseURL = "https://fill.papermc.io/v3"
+
+// serverDownload is the key of the server jar in a build's downloads.
+const serverDownload = "server:default"
+
+// Channel is the release channel of a PaperMC build, from most to least
+// stable.
+type Channel string
+
+const (
+	ChannelStable Channel = "STABLE"
+	ChannelBeta   Channel = "BETA"
+	ChannelAlpha  Channel = "ALPHA"
+)
+
+// rank orders channels by stability, lower being more stable.
+func (ch Channel) rank() int {
+	switch Channel(strings.ToUpper(string(ch))) {
+	case ChannelStable:
+		return 0
+	case ChannelBeta:
+		return 1
+	default:
+		return 2
+	}
+}
+
+type build struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Channel Channel   `json:"channel"`
+	Commits []struct {
+		SHA     string    `json:"sha"`
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+	} `json:"commits"`
+	Downloads map[string]struct {
+		Name      string `json:"name"`
+		Checksums struct {
+			SHA256 string `json:"sha256"`
+		} `json:"checksums"`
+		Size int64  `json:"size"`
+		URL  string `json:"url"`
+	} `json:"downloads"`
+}
+
+type version struct {
+	Version struct {
+		ID   string `json:"id"`
+		Java struct {
+			Version struct {
+				Minimum int `json:"minimum"`
+			} `json:"version"`
+			Flags struct {
+				Recommended []string `json:"recommended"`
+			} `json:"flags"`
+		} `json:"java"`
+	} `json:"version"`
+	Builds []int `json:"builds"`
+}
+
+var (
+	_ jarchive.Jarchive      = (*Config)(nil)
+	_ jarchive.VersionLister = (*Config)(nil)
+	_ jarchive.BuildLister   = (*Config)(nil)
+)
 
 type Config struct {
+	// Version is the Minecraft version, or the proxy version for Velocity
+	// and Waterfall.
 	Version string
+	Build   int // build number to resolve; zero selects the latest build
+
+	project string
+	client  *httputil.Client
+	baseURL string
+	channel Channel
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHTTPClient is the provider-level form of jarchive.WithHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return withOptions(jarchive.WithHTTPClient(client))
+}
+
+// WithCache is the provider-level form of jarchive.WithCache.
+func WithCache(store cache.Cache, ttl time.Duration) Option {
+	return withOptions(jarchive.WithCache(store, ttl))
+}
+
+// WithOffline is the provider-level form of jarchive.WithOffline.
+func WithOffline(offline bool) Option {
+	return withOptions(jarchive.WithOffline(offline))
+}
+
+// WithRetryPolicy is the provider-level form of jarchive.WithRetryPolicy.
+func WithRetryPolicy(policy jarchive.RetryPolicy) Option {
+	return withOptions(jarchive.WithRetryPolicy(policy))
 }
 
-func New(version string) *Config {
-	return &Config{
+// WithUserAgent is the provider-level form of jarchive.WithUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return withOptions(jarchive.WithUserAgent(userAgent))
+}
+
+// WithChannel sets the least stable channel the latest build is picked from.
+// The default, ChannelStable, never resolves beta or alpha builds.
+func WithChannel(channel Channel) Option {
+	return func(c *Config) {
+		c.channel = channel
+	}
+}
+
+// WithBaseURL sets the PaperMC Fill v3 API base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) {
+		c.baseURL = url
+	}
+}
+
+// New returns a Config for the Paper server.
+func New(version string, opts ...Option) *Config {
+	return NewProject(ProjectPaper, version, opts...)
+}
+
+// NewProject returns a Config for any project on the PaperMC API, such as
+// ProjectFolia or ProjectVelocity.
+func NewProject(project, version string, opts ...Option) *Config {
+	c := &Config{
 		Version: version,
+		project: project,
+		client:  httputil.NewClient(),
+		baseURL: defaultBaseURL,
+		channel: ChannelStable,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func init() {
+	for _, project := range []string{ProjectPaper, ProjectFolia, ProjectVelocity, ProjectWaterfall} {
+		jarchive.Register(project, func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+			return NewProject(project, version, withOptions(opts...)), nil
+		})
+	}
+}
+
+// withOptions applies the provider-independent jarchive options.
+func withOptions(opts ...jarchive.Option) Option {
+	return func(c *Config) {
+		c.client.Apply(opts...)
 	}
 }
 
 func (c *Config) Mirror() (string, error) {
-	latestVersion, err := getLatestBuild(c.Version)
+	return c.MirrorContext(context.Background())
+}
+
+func (c *Config) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := c.ResolveContext(ctx)
 	if err != nil {
 		return "", err
 	}
+	return artifact.URL, nil
+}
+
+// Resolve returns the server or proxy jar of the configured build, or of the latest
+// build in the selected channel when Build is zero.
+func (c *Config) Resolve() (*jarchive.Artifact, error) {
+	return c.ResolveContext(context.Background())
+}
 
-	url, err := utils.URLJoin(
-		baseURL,
-		"versions",
-		c.Version,
-		"builds",
-		strconv.Itoa(latestVersion),
-		"downloads",
-		fmt.Sprintf("paper-%s-%d.jar", c.Version, latestVersion),
-	)
+// ResolveContext is like Resolve but honors the deadline and cancellation of ctx.
+func (c *Config) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	v, err := c.getVersion(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	resp, err := http.Head(url)
+	b, err := c.getBuild(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	download, ok := b.Downloads[serverDownload]
+	if !ok || download.URL == "" {
+		return nil, fmt.Errorf("%w: build %d of version %s", jarchive.ErrNoServerArtifact, b.ID, c.Version)
+	}
+
+	// The name is used as a local path, so only its last element is kept.
+	// Older builds may lack a name; the download URL ends in the same one.
+	fileName, ok := utils.FileName(download.Name)
+	if !ok {
+		fileName, ok = downloadFileName(download.URL)
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: build %d of version %s has no usable file name", jarchive.ErrNoServerArtifact, b.ID, c.Version)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid version")
+	kind := jarchive.KindServer
+	if slices.Contains(proxyProjects, c.project) {
+		kind = jarchive.KindProxy
 	}
 
-	return url, nil
+	artifact := &jarchive.Artifact{
+		URL:         download.URL,
+		FileName:    fileName,
+		Provider:    c.project,
+		Kind:        kind,
+		Version:     c.Version,
+		Build:       strconv.Itoa(b.ID),
+		Size:        download.Size,
+		ReleaseTime: b.Time,
+	}
+	if sum := download.Checksums.SHA256; sum != "" {
+		artifact.Checksum = sum
+		artifact.ChecksumAlgorithm = jarchive.SHA256
+	}
+	if java := v.Version.Java; java.Version.Minimum > 0 {
+		artifact.Java = &jarchive.JavaRuntime{
+			MajorVersion: java.Version.Minimum,
+			Flags:        java.Flags.Recommended,
+		}
+	}
+
+	return artifact, nil
+}
+
+// downloadFileName returns the file name at the end of the path of rawURL.
+func downloadFileName(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	return utils.FileName(u.Path)
 }
 
-func getLatestBuild(version string) (int, error) {
-	url, err := utils.URLJoin(baseURL, "versions", version)
+// getVersion fetches the configured version.
+func (c *Config) getVersion(ctx context.Context) (*version, error) {
+	url, err := utils.URLJoin(c.baseURL, "projects", c.project, "versions", c.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	data := new(version)
+	err = c.client.GetJSON(ctx, url, data)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s: %w", jarchive.ErrVersionNotFound, c.Version, err)
+	}
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// getBuild returns the pinned build, or the latest one in the selected
+// channel when Build is zero. A pinned build is returned whatever its channel.
+func (c *Config) getBuild(ctx context.Context) (*build, error) {
+	if c.Build == 0 {
+		return c.getLatestBuild(ctx)
 	}
 
-	resp, err := http.Get(url)
+	builds, err := c.getBuilds(ctx)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return 0, fmt.Errorf("invalid version")
+	for i := range builds {
+		if builds[i].ID == c.Build {
+			return &builds[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: build %d not found for version %s", jarchive.ErrBuildNotFound, c.Build, c.Version)
+}
+
+func (c *Config) getLatestBuild(ctx context.Context) (*build, error) {
+	builds, err := c.getBuilds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range builds {
+		if c.inChannel(builds[i]) {
+			return &builds[i], nil
+		}
+	}
+
+	if len(builds) == 0 {
+		return nil, fmt.Errorf("%w: no builds found for version %s", jarchive.ErrBuildNotFound, c.Version)
+	}
+	return nil, fmt.Errorf("%w: no %s builds found for version %s", jarchive.ErrBuildNotFound, strings.ToLower(string(c.channel)), c.Version)
+}
+
+// inChannel reports whether b is at least as stable as the selected channel.
+func (c *Config) inChannel(b build) bool {
+	return b.Channel.rank() <= c.channel.rank()
+}
+
+// getBuilds fetches every build of the configured version, newest first.
+func (c *Config) getBuilds(ctx context.Context) ([]build, error) {
+	url, err := utils.URLJoin(c.baseURL, "projects", c.project, "versions", c.Version, "builds")
+	if err != nil {
+		return nil, err
+	}
+
+	var builds []build
+	err = c.client.GetJSON(ctx, url, &builds)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s: %w", jarchive.ErrVersionNotFound, c.Version, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return builds, nil
+}
+
+// ListBuilds returns every build of the configured version, whatever its
+// channel. The channel selected with WithChannel only applies to resolution.
+func (c *Config) ListBuilds(ctx context.Context) ([]jarchive.Build, error) {
+	builds, err := c.getBuilds(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]jarchive.Build, 0, len(builds))
+	for _, b := range builds {
+		changes := make([]jarchive.Change, 0, len(b.Commits))
+		for _, commit := range b.Commits {
+			summary, _, _ := strings.Cut(commit.Message, "\n")
+			changes = append(changes, jarchive.Change{
+				Commit:  commit.SHA,
+				Summary: summary,
+				Message: commit.Message,
+			})
+		}
+		build := jarchive.Build{
+			ID:      strconv.Itoa(b.ID),
+			Time:    b.Time,
+			Channel: string(b.Channel),
+			Changes: changes,
+		}
+		if sum := b.Downloads[serverDownload].Checksums.SHA256; sum != "" {
+			build.Checksum = sum
+			build.ChecksumAlgorithm = jarchive.SHA256
+		}
+		result = append(result, build)
+	}
+
+	return result, nil
+}
+
+// ListVersions returns the versions the project has builds for.
+func (c *Config) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	url, err := utils.URLJoin(c.baseURL, "projects", c.project, "versions")
+	if err != nil {
+		return nil, err
 	}
 
 	var data struct {
-		Builds []int `json:"builds"`
+		Versions []version `json:"versions"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return 0, err
+	if err := c.client.GetJSON(ctx, url, &data); err != nil {
+		return nil, err
 	}
 
-	if len(data.Builds) == 0 {
-		return 0, fmt.Errorf("no builds found for version %s", version)
+	// The API lists versions newest first.
+	versions := make([]jarchive.Version, 0, len(data.Versions))
+	for _, v := range data.Versions {
+		if len(v.Builds) == 0 {
+			continue
+		}
+		versions = append(versions, jarchive.Version{ID: v.Version.ID})
 	}
 
-	return data.Builds[len(data.Builds)-1], nil
+	return versions, nil
 }
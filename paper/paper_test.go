@@ -1,42 +1,115 @@
 package paper
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
 	"github.com/stretchr/testify/assert"
 )
 
+// noRetry keeps tests of failing upstreams fast.
+var noRetry = WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 1})
+
+// testBuild returns a Fill v3 build with a server download.
+func testBuild(id int, channel string) map[string]any {
+	return map[string]any{
+		"id":      id,
+		"time":    "2022-06-01T12:00:00Z",
+		"channel": channel,
+		"commits": []map[string]any{},
+		"downloads": map[string]any{
+			"server:default": map[string]any{
+				"name":      "paper-1.18.2-" + strconv.Itoa(id) + ".jar",
+				"checksums": map[string]any{"sha256": "abc123"},
+				"size":      4096,
+				"url":       "https://fill-data.papermc.io/v1/objects/abc123/paper-1.18.2-" + strconv.Itoa(id) + ".jar",
+			},
+		},
+	}
+}
+
+// newFillServer serves the version 1.18.2 with the given builds, newest first,
+// under /v3/projects/paper.
+func newFillServer(t *testing.T, builds ...map[string]any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/paper/versions/1.18.2":
+			response := map[string]any{
+				"version": map[string]any{
+					"id":      "1.18.2",
+					"support": map[string]any{"status": "UNSUPPORTED"},
+					"java": map[string]any{
+						"version": map[string]any{"minimum": 17},
+						"flags":   map[string]any{"recommended": []string{"-XX:+UseG1GC"}},
+					},
+				},
+				"builds": []int{},
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+		case "/v3/projects/paper/versions/1.18.2/builds":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(builds)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
 func TestNew(t *testing.T) {
 	config := New("1.18.2")
 	assert.Equal(t, "1.18.2", config.Version)
+	assert.Equal(t, http.DefaultClient, config.client.HTTP)
+	assert.Nil(t, config.client.Cache)
+	assert.Equal(t, defaultBaseURL, config.baseURL)
+	assert.Equal(t, ChannelStable, config.channel)
+	assert.Equal(t, httputil.DefaultUserAgent, config.client.UserAgent)
 }
 
-func TestMirror_Success(t *testing.T) {
-	buildsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := map[string]any{
-			"builds": []int{100, 101, 102},
-		}
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(response)
-	}))
-	defer buildsServer.Close()
+func TestNew_WithOptions(t *testing.T) {
+	client := &http.Client{}
+	store := cache.NewMemory()
+	config := New("1.18.2", WithHTTPClient(client), WithCache(store, time.Minute), WithOffline(true), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}), WithUserAgent("test/1.0"), WithChannel(ChannelBeta), WithBaseURL("https://api.example.com/v3"))
+	assert.Same(t, client, config.client.HTTP)
+	assert.Same(t, store, config.client.Cache)
+	assert.Equal(t, time.Minute, config.client.TTL)
+	assert.Equal(t, "https://api.example.com/v3", config.baseURL)
+	assert.True(t, config.client.Offline)
+	assert.Equal(t, 5, config.client.Retry.MaxAttempts)
+	assert.Equal(t, "test/1.0", config.client.UserAgent)
+	assert.Equal(t, ChannelBeta, config.channel)
+}
 
-	downloadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer downloadServer.Close()
+func TestRegistered(t *testing.T) {
+	client := &http.Client{}
+	provider, err := jarchive.New("paper", "1.18.2", jarchive.WithHTTPClient(client), jarchive.WithUserAgent("test/1.0"))
 
-	baseURL = buildsServer.URL + "/v2/projects/paper"
+	assert.NoError(t, err)
+	config, ok := provider.(*Config)
+	assert.True(t, ok)
+	assert.Equal(t, "1.18.2", config.Version)
+	assert.Same(t, client, config.client.HTTP)
+	assert.Equal(t, "test/1.0", config.client.UserAgent)
+}
 
-	config := New("1.18.2")
+func TestMirror_Success(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"), testBuild(101, "STABLE"), testBuild(100, "STABLE"))
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
 	mirrorURL, err := config.Mirror()
 
 	assert.NoError(t, err)
-	expectedURL := buildsServer.URL + "/v2/projects/paper/versions/1.18.2/builds/102/downloads/paper-1.18.2-102.jar"
-	assert.Equal(t, expectedURL, mirrorURL)
+	assert.Equal(t, "https://fill-data.papermc.io/v1/objects/abc123/paper-1.18.2-102.jar", mirrorURL)
 }
 
 func TestMirror_InvalidVersion(t *testing.T) {
@@ -45,61 +118,354 @@ func TestMirror_InvalidVersion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/projects/paper"
-
-	config := New("invalid-version")
+	config := New("invalid-version", WithBaseURL(server.URL+"/v3"))
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
 }
 
 func TestGetLatestBuild_Success(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"), testBuild(101, "STABLE"))
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	latestBuild, err := config.getLatestBuild(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 102, latestBuild.ID)
+	assert.Equal(t, "abc123", latestBuild.Downloads[serverDownload].Checksums.SHA256)
+}
+
+func TestGetLatestBuild_NoBuilds(t *testing.T) {
+	server := newFillServer(t)
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "no builds found for version")
+}
+
+func TestGetLatestBuild_InvalidVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := New("invalid-version", WithBaseURL(server.URL+"/v3"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+}
+
+func TestGetLatestBuild_Channels(t *testing.T) {
+	server := newFillServer(t, testBuild(103, "ALPHA"), testBuild(102, "BETA"), testBuild(101, "STABLE"))
+
+	tests := []struct {
+		channel Channel
+		want    int
+	}{
+		{ChannelStable, 101},
+		{ChannelBeta, 102},
+		{ChannelAlpha, 103},
+		{"stable", 101},
+		{"experimental", 103},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.channel), func(t *testing.T) {
+			config := New("1.18.2", WithBaseURL(server.URL+"/v3"), WithChannel(tt.channel))
+			latestBuild, err := config.getLatestBuild(context.Background())
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, latestBuild.ID)
+		})
+	}
+}
+
+func TestGetLatestBuild_NoStableBuilds(t *testing.T) {
+	server := newFillServer(t, testBuild(2, "ALPHA"), testBuild(1, "ALPHA"))
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "no stable builds found for version 1.18.2")
+}
+
+func TestMirrorContext_Canceled(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		response := map[string]any{
-			"builds": []int{100, 101, 102},
-		}
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(response)
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/projects/paper"
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	_, err := config.MirrorContext(ctx)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolve_Success(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"), testBuild(101, "STABLE"))
 
-	latestBuild, err := getLatestBuild("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	artifact, err := config.Resolve()
 
 	assert.NoError(t, err)
-	assert.Equal(t, 102, latestBuild)
+	assert.Equal(t, "https://fill-data.papermc.io/v1/objects/abc123/paper-1.18.2-102.jar", artifact.URL)
+	assert.Equal(t, "paper-1.18.2-102.jar", artifact.FileName)
+	assert.Equal(t, "paper", artifact.Provider)
+	assert.Equal(t, jarchive.KindServer, artifact.Kind)
+	assert.Equal(t, "102", artifact.Build)
+	assert.Equal(t, "abc123", artifact.Checksum)
+	assert.Equal(t, jarchive.SHA256, artifact.ChecksumAlgorithm)
+	assert.Equal(t, int64(4096), artifact.Size)
+	assert.Equal(t, time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC), artifact.ReleaseTime)
+	assert.Equal(t, &jarchive.JavaRuntime{MajorVersion: 17, Flags: []string{"-XX:+UseG1GC"}}, artifact.Java)
 }
 
-func TestGetLatestBuild_NoBuilds(t *testing.T) {
+func TestResolve_SendsUserAgent(t *testing.T) {
+	var userAgents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents = append(userAgents, r.UserAgent())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := New("1.18.2", WithBaseURL(server.URL+"/v3"), WithUserAgent("my-panel/2.0 (ops@example.com)")).Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+	assert.Equal(t, []string{"my-panel/2.0 (ops@example.com)"}, userAgents)
+}
+
+func TestResolve_NoServerDownload(t *testing.T) {
+	b := testBuild(102, "STABLE")
+	b["downloads"] = map[string]any{}
+	server := newFillServer(t, b)
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
+}
+
+func TestListVersions_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/projects/paper/versions", r.URL.Path)
 		response := map[string]any{
-			"builds": []int{},
+			"versions": []map[string]any{
+				{"version": map[string]any{"id": "1.19"}, "builds": []int{}},
+				{"version": map[string]any{"id": "1.18.2"}, "builds": []int{102, 101}},
+				{"version": map[string]any{"id": "1.18.1"}, "builds": []int{100}},
+			},
 		}
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/projects/paper"
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	versions, err := config.ListVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Version{{ID: "1.18.2"}, {ID: "1.18.1"}}, versions)
+}
+
+func TestResolve_PinnedBuild(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"), testBuild(101, "ALPHA"))
 
-	_, err := getLatestBuild("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	config.Build = 101
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "101", artifact.Build)
+	assert.Equal(t, "https://fill-data.papermc.io/v1/objects/abc123/paper-1.18.2-101.jar", artifact.URL)
+}
+
+func TestResolve_PinnedBuildNotFound(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"))
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	config.Build = 99
+	_, err := config.Resolve()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "no builds found for version")
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "build 99 not found for version 1.18.2")
 }
 
-func TestGetLatestBuild_InvalidVersion(t *testing.T) {
+func TestListBuilds_Success(t *testing.T) {
+	b102 := testBuild(102, "BETA")
+	b102["time"] = "2022-06-02T12:00:00Z"
+	b102["commits"] = []map[string]any{
+		{"sha": "abc123", "time": "2022-06-02T11:00:00Z", "message": "Fix things\n\nLonger description"},
+	}
+	server := newFillServer(t, testBuild(103, "ALPHA"), b102, testBuild(101, "STABLE"))
+
+	// The default stable channel does not hide other builds from the list.
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"))
+	builds, err := config.ListBuilds(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Build{
+		{
+			ID:      "103",
+			Time:    time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+			Channel: "ALPHA",
+			Changes: []jarchive.Change{},
+
+			Checksum:          "abc123",
+			ChecksumAlgorithm: jarchive.SHA256,
+		},
+		{
+			ID:      "102",
+			Time:    time.Date(2022, 6, 2, 12, 0, 0, 0, time.UTC),
+			Channel: "BETA",
+			Changes: []jarchive.Change{{Commit: "abc123", Summary: "Fix things", Message: "Fix things\n\nLonger description"}},
+
+			Checksum:          "abc123",
+			ChecksumAlgorithm: jarchive.SHA256,
+		},
+		{
+			ID:      "101",
+			Time:    time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC),
+			Channel: "STABLE",
+			Changes: []jarchive.Change{},
+
+			Checksum:          "abc123",
+			ChecksumAlgorithm: jarchive.SHA256,
+		},
+	}, builds)
+}
+
+func TestMirror_UpstreamError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/projects/paper"
+	config := New("1.18.2", WithBaseURL(server.URL+"/v3"), noRetry)
+	_, err := config.Mirror()
 
-	_, err := getLatestBuild("invalid-version")
+	assert.NotErrorIs(t, err, jarchive.ErrVersionNotFound)
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusServiceUnavailable, upstreamErr.StatusCode)
+	assert.True(t, upstreamErr.Temporary())
+}
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+func TestResolve_Offline(t *testing.T) {
+	server := newFillServer(t, testBuild(102, "STABLE"))
+	baseURL := server.URL + "/v3"
+
+	store := cache.NewMemory()
+	online, err := New("1.18.2", WithBaseURL(baseURL), WithCache(store, time.Hour)).Resolve()
+	assert.NoError(t, err)
+	server.Close()
+
+	offline, err := New("1.18.2", WithBaseURL(baseURL), WithCache(store, time.Hour), WithOffline(true)).Resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, online, offline)
+
+	_, err = New("1.19", WithBaseURL(baseURL), WithCache(store, time.Hour), WithOffline(true)).Resolve()
+	assert.ErrorIs(t, err, jarchive.ErrOffline)
+}
+
+func TestRegistered_Projects(t *testing.T) {
+	for _, project := range []string{ProjectFolia, ProjectVelocity, ProjectWaterfall} {
+		provider, err := jarchive.New(project, "1.0")
+
+		assert.NoError(t, err)
+		config, ok := provider.(*Config)
+		assert.True(t, ok)
+		assert.Equal(t, project, config.project)
+	}
+}
+
+func TestResolve_Velocity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v3/projects/velocity/versions/3.4.0-SNAPSHOT":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(map[string]any{
+				"version": map[string]any{"id": "3.4.0-SNAPSHOT"},
+				"builds":  []int{500},
+			})
+		case "/v3/projects/velocity/versions/3.4.0-SNAPSHOT/builds":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]map[string]any{{
+				"id":      500,
+				"channel": "STABLE",
+				"downloads": map[string]any{
+					"server:default": map[string]any{
+						"name": "velocity-3.4.0-SNAPSHOT-500.jar",
+						"url":  "https://fill-data.papermc.io/v1/objects/def456/velocity-3.4.0-SNAPSHOT-500.jar",
+					},
+				},
+			}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	artifact, err := NewProject(ProjectVelocity, "3.4.0-SNAPSHOT", WithBaseURL(server.URL+"/v3")).Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "velocity", artifact.Provider)
+	assert.Equal(t, jarchive.KindProxy, artifact.Kind)
+	assert.Equal(t, "velocity-3.4.0-SNAPSHOT-500.jar", artifact.FileName)
+	assert.Equal(t, "500", artifact.Build)
+	assert.Nil(t, artifact.Java)
+}
+
+func TestResolve_FileNameFromURL(t *testing.T) {
+	b := testBuild(102, "STABLE")
+	download := b["downloads"].(map[string]any)["server:default"].(map[string]any)
+	delete(download, "name")
+	server := newFillServer(t, b)
+
+	artifact, err := New("1.18.2", WithBaseURL(server.URL+"/v3")).Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "paper-1.18.2-102.jar", artifact.FileName)
+}
+
+func TestResolve_UnsafeFileName(t *testing.T) {
+	tests := map[string]string{
+		"../../x.jar":     "x.jar",
+		`..\..\x.jar`:     "paper-1.18.2-102.jar",
+		"..":              "paper-1.18.2-102.jar",
+		"builds/../x.jar": "x.jar",
+	}
+
+	for name, want := range tests {
+		b := testBuild(102, "STABLE")
+		b["downloads"].(map[string]any)["server:default"].(map[string]any)["name"] = name
+		server := newFillServer(t, b)
+
+		artifact, err := New("1.18.2", WithBaseURL(server.URL+"/v3")).Resolve()
+
+		assert.NoError(t, err, name)
+		assert.Equal(t, want, artifact.FileName, name)
+	}
+}
+
+func TestResolve_NoUsableFileName(t *testing.T) {
+	b := testBuild(102, "STABLE")
+	download := b["downloads"].(map[string]any)["server:default"].(map[string]any)
+	download["name"] = ".."
+	download["url"] = "https://fill-data.papermc.io/"
+	server := newFillServer(t, b)
+
+	_, err := New("1.18.2", WithBaseURL(server.URL+"/v3")).Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
 }
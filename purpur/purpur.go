@@ -1,83 +1,311 @@
 package purpur
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
 	"github.com/ciathefed/jarchive/internal/utils"
 )
 
-var baseURL = "https://api.purpurmc.org/v2/purpur"
+const providerName = "purpur"
+
+const defaultBaseURL = "https://api.purpurmc.org/v2/purpur"
+
+type build struct {
+	Build     string `json:"build"`
+	Result    string `json:"result"`
+	Timestamp int64  `json:"timestamp"`
+	MD5       string `json:"md5"`
+	Commits   []struct {
+		Hash        string `json:"hash"`
+		Description string `json:"description"`
+	} `json:"commits"`
+}
+
+// buildSuccess is the result of a build that produced a server jar.
+const buildSuccess = "SUCCESS"
+
+// time returns when the build was made, or the zero time if unknown.
+func (b *build) time() time.Time {
+	if b.Timestamp <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(b.Timestamp).UTC()
+}
+
+// toBuild converts b to a jarchive.Build.
+func (b *build) toBuild() jarchive.Build {
+	changes := make([]jarchive.Change, 0, len(b.Commits))
+	for _, commit := range b.Commits {
+		summary, _, _ := strings.Cut(commit.Description, "\n")
+		changes = append(changes, jarchive.Change{
+			Commit:  commit.Hash,
+			Summary: summary,
+			Message: commit.Description,
+		})
+	}
+
+	result := jarchive.Build{
+		ID:      b.Build,
+		Time:    b.time(),
+		Result:  b.Result,
+		Changes: changes,
+	}
+	if b.MD5 != "" {
+		result.Checksum = b.MD5
+		result.ChecksumAlgorithm = jarchive.MD5
+	}
+	return result
+}
+
+var (
+	_ jarchive.Jarchive      = (*Config)(nil)
+	_ jarchive.VersionLister = (*Config)(nil)
+	_ jarchive.BuildLister   = (*Config)(nil)
+)
 
 type Config struct {
 	Version string
+	Build   string // build number to resolve; empty selects the latest build
+
+	client  *httputil.Client
+	baseURL string
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHTTPClient is the provider-level form of jarchive.WithHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return withOptions(jarchive.WithHTTPClient(client))
+}
+
+// WithCache is the provider-level form of jarchive.WithCache.
+func WithCache(store cache.Cache, ttl time.Duration) Option {
+	return withOptions(jarchive.WithCache(store, ttl))
 }
 
-func New(version string) *Config {
-	return &Config{
+// WithOffline is the provider-level form of jarchive.WithOffline.
+func WithOffline(offline bool) Option {
+	return withOptions(jarchive.WithOffline(offline))
+}
+
+// WithRetryPolicy is the provider-level form of jarchive.WithRetryPolicy.
+func WithRetryPolicy(policy jarchive.RetryPolicy) Option {
+	return withOptions(jarchive.WithRetryPolicy(policy))
+}
+
+// WithUserAgent is the provider-level form of jarchive.WithUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return withOptions(jarchive.WithUserAgent(userAgent))
+}
+
+// WithBaseURL sets the Purpur API base URL.
+func WithBaseURL(url string) Option {
+	return func(c *Config) {
+		c.baseURL = url
+	}
+}
+
+func New(version string, opts ...Option) *Config {
+	c := &Config{
 		Version: version,
+		client:  httputil.NewClient(),
+		baseURL: defaultBaseURL,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func init() {
+	jarchive.Register(providerName, func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+		return New(version, withOptions(opts...)), nil
+	})
+}
+
+// withOptions applies the provider-independent jarchive options.
+func withOptions(opts ...jarchive.Option) Option {
+	return func(c *Config) {
+		c.client.Apply(opts...)
 	}
 }
 
 func (c *Config) Mirror() (string, error) {
-	latestVersion, err := getLatestBuild(c.Version)
+	return c.MirrorContext(context.Background())
+}
+
+func (c *Config) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := c.ResolveContext(ctx)
 	if err != nil {
 		return "", err
 	}
+	return artifact.URL, nil
+}
+
+// Resolve returns the server jar of the configured build, or of the latest
+// successful build when Build is empty. The artifact carries the build's MD5,
+// which Download verifies.
+func (c *Config) Resolve() (*jarchive.Artifact, error) {
+	return c.ResolveContext(context.Background())
+}
+
+// ResolveContext is like Resolve but honors the deadline and cancellation of ctx.
+func (c *Config) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	var details *build
+	var err error
+	if c.Build == "" {
+		details, err = c.getLatestBuild(ctx)
+	} else {
+		details, err = c.getBuild(ctx, c.Build)
+		if err == nil && details.Result != buildSuccess {
+			err = fmt.Errorf("%w: build %s of version %s has result %s", jarchive.ErrNoServerArtifact, c.Build, c.Version, details.Result)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	buildNumber := details.Build
 
 	url, err := utils.URLJoin(
-		baseURL,
+		c.baseURL,
 		c.Version,
-		latestVersion,
+		buildNumber,
 		"download",
 	)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	resp, err := http.Head(url)
+	resp, err := c.client.Head(ctx, url)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: build %s of version %s: %w", jarchive.ErrNoServerArtifact, buildNumber, c.Version, err)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid version")
+	artifact := &jarchive.Artifact{
+		URL:         url,
+		FileName:    fmt.Sprintf("purpur-%s-%s.jar", c.Version, buildNumber),
+		Provider:    providerName,
+		Kind:        jarchive.KindServer,
+		Version:     c.Version,
+		Build:       buildNumber,
+		ReleaseTime: details.time(),
+	}
+	if details.MD5 != "" {
+		artifact.Checksum = details.MD5
+		artifact.ChecksumAlgorithm = jarchive.MD5
+	}
+	if resp.ContentLength > 0 {
+		artifact.Size = resp.ContentLength
 	}
 
-	return url, nil
+	return artifact, nil
 }
 
-func getLatestBuild(version string) (string, error) {
-	url, err := utils.URLJoin(baseURL, version)
+// getLatestBuild returns the details of the newest build that succeeded,
+// skipping failed ones.
+func (c *Config) getLatestBuild(ctx context.Context) (*build, error) {
+	builds, err := c.getBuilds(ctx)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	if len(builds) == 0 {
+		return nil, fmt.Errorf("%w: no builds found for version %s", jarchive.ErrBuildNotFound, c.Version)
 	}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
+	// The API lists builds oldest first.
+	for i := len(builds) - 1; i >= 0; i-- {
+		if builds[i].Result == buildSuccess {
+			return &builds[i], nil
+		}
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode > 399 {
-		return "", fmt.Errorf("invalid version")
+	return nil, fmt.Errorf("%w: no successful builds found for version %s", jarchive.ErrBuildNotFound, c.Version)
+}
+
+// getBuilds returns the details of every build of the configured version,
+// oldest first, in a single request.
+func (c *Config) getBuilds(ctx context.Context) ([]build, error) {
+	url, err := utils.URLJoin(c.baseURL, c.Version)
+	if err != nil {
+		return nil, err
 	}
 
 	var data struct {
 		Builds struct {
-			Latest string   `json:"string"`
-			All    []string `json:"all"`
+			All []build `json:"all"`
 		} `json:"builds"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return "", err
+	err = c.client.GetJSON(ctx, url+"?detailed=true", &data)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %s: %w", jarchive.ErrVersionNotFound, c.Version, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Builds.All, nil
+}
+
+func (c *Config) getBuild(ctx context.Context, buildNumber string) (*build, error) {
+	url, err := utils.URLJoin(c.baseURL, c.Version, buildNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	data := new(build)
+	err = c.client.GetJSON(ctx, url, data)
+	if httputil.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: build %s of version %s: %w", jarchive.ErrBuildNotFound, buildNumber, c.Version, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// ListVersions returns the Minecraft versions the project has builds for.
+func (c *Config) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	var data struct {
+		Versions []string `json:"versions"`
+	}
+	if err := c.client.GetJSON(ctx, c.baseURL, &data); err != nil {
+		return nil, err
+	}
+
+	// The API lists versions oldest first.
+	versions := make([]jarchive.Version, 0, len(data.Versions))
+	for i := len(data.Versions) - 1; i >= 0; i-- {
+		versions = append(versions, jarchive.Version{ID: data.Versions[i]})
+	}
+
+	return versions, nil
+}
+
+// ListBuilds returns every build of the configured version, failed ones
+// included; check Result to tell them apart.
+func (c *Config) ListBuilds(ctx context.Context) ([]jarchive.Build, error) {
+	all, err := c.getBuilds(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	if data.Builds.Latest == "" {
-		return data.Builds.All[len(data.Builds.All)-1], nil
+	builds := make([]jarchive.Build, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		builds = append(builds, all[i].toBuild())
 	}
 
-	return data.Builds.Latest, nil
+	return builds, nil
 }
@@ -1,17 +1,48 @@
 package purpur
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNew(t *testing.T) {
 	config := New("1.18.2")
 	assert.Equal(t, "1.18.2", config.Version)
+	assert.Equal(t, http.DefaultClient, config.client.HTTP)
+	assert.Nil(t, config.client.Cache)
+	assert.Equal(t, defaultBaseURL, config.baseURL)
+}
+
+func TestNew_WithOptions(t *testing.T) {
+	client := &http.Client{}
+	store := cache.NewMemory()
+	config := New("1.18.2", WithHTTPClient(client), WithCache(store, time.Minute), WithOffline(true), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}), WithBaseURL("https://api.example.com/v2/purpur"))
+	assert.Same(t, client, config.client.HTTP)
+	assert.Same(t, store, config.client.Cache)
+	assert.Equal(t, time.Minute, config.client.TTL)
+	assert.Equal(t, "https://api.example.com/v2/purpur", config.baseURL)
+	assert.True(t, config.client.Offline)
+	assert.Equal(t, 5, config.client.Retry.MaxAttempts)
+}
+
+func TestRegistered(t *testing.T) {
+	client := &http.Client{}
+	provider, err := jarchive.New("purpur", "1.18.2", jarchive.WithHTTPClient(client))
+
+	assert.NoError(t, err)
+	config, ok := provider.(*Config)
+	assert.True(t, ok)
+	assert.Equal(t, "1.18.2", config.Version)
+	assert.Same(t, client, config.client.HTTP)
 }
 
 func TestMirror_Success(t *testing.T) {
@@ -21,7 +52,10 @@ func TestMirror_Success(t *testing.T) {
 			response := map[string]any{
 				"builds": map[string]any{
 					"latest": "123",
-					"all":    []string{"120", "121", "122", "123"},
+					"all": []map[string]any{
+						{"build": "122", "result": "SUCCESS"},
+						{"build": "123", "result": "SUCCESS"},
+					},
 				},
 			}
 			w.WriteHeader(http.StatusOK)
@@ -34,9 +68,7 @@ func TestMirror_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/purpur"
-
-	config := New("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
 	mirrorURL, err := config.Mirror()
 
 	assert.NoError(t, err)
@@ -49,21 +81,97 @@ func TestMirror_InvalidVersion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/purpur"
-
-	config := New("invalid-version")
+	config := New("invalid-version", WithBaseURL(server.URL+"/v2/purpur"))
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+}
+
+// newPurpurServer serves version 1.18.2 with the given builds, oldest first,
+// and their results. Builds without a result are still building.
+func newPurpurServer(t *testing.T, latest string, builds []string, results map[string]string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v2/purpur/1.18.2" {
+			assert.Equal(t, "true", r.URL.Query().Get("detailed"))
+			all := make([]map[string]any, 0, len(builds))
+			for _, number := range builds {
+				all = append(all, map[string]any{"build": number, "result": results[number]})
+			}
+			response := map[string]any{
+				"builds": map[string]any{"latest": latest, "all": all},
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+
+		number, download := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/v2/purpur/1.18.2/"), "/download")
+		result, ok := results[number]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if !download {
+			json.NewEncoder(w).Encode(map[string]any{"build": number, "result": result})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
 }
 
 func TestGetLatestBuild_Success(t *testing.T) {
+	server := newPurpurServer(t, "123", []string{"120", "121", "122", "123"}, map[string]string{"123": "SUCCESS"})
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	latestBuild, err := config.getLatestBuild(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "123", latestBuild.Build)
+}
+
+func TestGetLatestBuild_NoLatestBuild(t *testing.T) {
+	server := newPurpurServer(t, "", []string{"120", "121", "122", "123"}, map[string]string{"123": "SUCCESS"})
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	latestBuild, err := config.getLatestBuild(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "123", latestBuild.Build)
+}
+
+func TestGetLatestBuild_SkipsFailedBuilds(t *testing.T) {
+	server := newPurpurServer(t, "123", []string{"120", "121", "122", "123"}, map[string]string{
+		"123": "FAILURE",
+		"122": "UNSTABLE",
+		"121": "SUCCESS",
+	})
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "121", artifact.Build)
+	assert.Equal(t, server.URL+"/v2/purpur/1.18.2/121/download", artifact.URL)
+}
+
+func TestGetLatestBuild_SingleRequest(t *testing.T) {
+	var requests []string
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path)
+		if r.URL.Path != "/v2/purpur/1.18.2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
 		response := map[string]any{
 			"builds": map[string]any{
 				"latest": "123",
-				"all":    []string{"120", "121", "122", "123"},
+				"all": []map[string]any{
+					{"build": "121", "result": "SUCCESS"},
+					{"build": "122", "result": "FAILURE"},
+					{"build": "123", "result": ""},
+				},
 			},
 		}
 		w.WriteHeader(http.StatusOK)
@@ -71,20 +179,192 @@ func TestGetLatestBuild_Success(t *testing.T) {
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/purpur"
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	latestBuild, err := config.getLatestBuild(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "121", latestBuild.Build)
+	assert.Equal(t, []string{"/v2/purpur/1.18.2"}, requests)
+}
+
+func TestGetLatestBuild_NoSuccessfulBuilds(t *testing.T) {
+	server := newPurpurServer(t, "2", []string{"1", "2"}, map[string]string{"1": "FAILURE", "2": "FAILURE"})
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "no successful builds found for version 1.18.2")
+}
+
+func TestGetLatestBuild_NoBuilds(t *testing.T) {
+	server := newPurpurServer(t, "", nil, nil)
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
+	assert.Contains(t, err.Error(), "no builds found for version 1.18.2")
+}
+
+func TestResolve_PinnedFailedBuild(t *testing.T) {
+	server := newPurpurServer(t, "123", []string{"122", "123"}, map[string]string{"122": "FAILURE", "123": "SUCCESS"})
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	config.Build = "122"
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
+	assert.Contains(t, err.Error(), "FAILURE")
+}
+
+func TestGetLatestBuild_InvalidVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := New("invalid-version", WithBaseURL(server.URL+"/v2/purpur"))
+	_, err := config.getLatestBuild(context.Background())
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+}
+
+func TestMirrorContext_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	_, err := config.MirrorContext(ctx)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolve_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/purpur/1.18.2":
+			response := map[string]any{
+				"builds": map[string]any{
+					"all": []map[string]any{
+						{"build": "122", "result": "SUCCESS"},
+						{
+							"build":     "123",
+							"result":    "SUCCESS",
+							"timestamp": 1654616406000,
+							"md5":       "0123456789abcdef0123456789abcdef",
+						},
+					},
+				},
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+		case "/v2/purpur/1.18.2/123/download":
+			w.Header().Set("Content-Length", "8192")
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, server.URL+"/v2/purpur/1.18.2/123/download", artifact.URL)
+	assert.Equal(t, "purpur-1.18.2-123.jar", artifact.FileName)
+	assert.Equal(t, "purpur", artifact.Provider)
+	assert.Equal(t, jarchive.KindServer, artifact.Kind)
+	assert.Equal(t, "123", artifact.Build)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", artifact.Checksum)
+	assert.Equal(t, jarchive.MD5, artifact.ChecksumAlgorithm)
+	assert.Equal(t, int64(8192), artifact.Size)
+	assert.Equal(t, time.UnixMilli(1654616406000).UTC(), artifact.ReleaseTime)
+}
+
+func TestGetBuild_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"build":  "123",
+			"result": "SUCCESS",
+			"md5":    "0123456789abcdef0123456789abcdef",
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	build, err := config.getBuild(context.Background(), "123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SUCCESS", build.Result)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", build.MD5)
+}
+
+func TestListVersions_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"project":  "purpur",
+			"versions": []string{"1.18.1", "1.18.2"},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
 
-	latestBuild, err := getLatestBuild("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	versions, err := config.ListVersions(context.Background())
 
 	assert.NoError(t, err)
-	assert.Equal(t, "123", latestBuild)
+	assert.Equal(t, []jarchive.Version{{ID: "1.18.2"}, {ID: "1.18.1"}}, versions)
 }
 
-func TestGetLatestBuild_NoLatestBuild(t *testing.T) {
+func TestResolve_PinnedBuild(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/purpur/1.18.2/121":
+			response := map[string]any{"build": "121", "result": "SUCCESS"}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(response)
+		case "/v2/purpur/1.18.2/121/download":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	config.Build = "121"
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "121", artifact.Build)
+	assert.Equal(t, server.URL+"/v2/purpur/1.18.2/121/download", artifact.URL)
+}
+
+func TestListBuilds_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/purpur/1.18.2", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("detailed"))
 		response := map[string]any{
 			"builds": map[string]any{
-				"latest": "",
-				"all":    []string{"120", "121", "122", "123"},
+				"latest": "123",
+				"all": []map[string]any{
+					{"build": "122", "result": "FAILURE", "timestamp": 1654616406000},
+					{"build": "123", "result": "SUCCESS", "timestamp": 1654702806000, "md5": "0123456789abcdef0123456789abcdef", "commits": []map[string]any{
+						{"hash": "abc123", "description": "Update upstream\n\nPaper changes"},
+					}},
+				},
 			},
 		}
 		w.WriteHeader(http.StatusOK)
@@ -92,24 +372,38 @@ func TestGetLatestBuild_NoLatestBuild(t *testing.T) {
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/purpur"
-
-	latestBuild, err := getLatestBuild("1.18.2")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	builds, err := config.ListBuilds(context.Background())
 
 	assert.NoError(t, err)
-	assert.Equal(t, "123", latestBuild)
+	assert.Equal(t, []jarchive.Build{
+		{
+			ID:      "123",
+			Time:    time.UnixMilli(1654702806000).UTC(),
+			Result:  "SUCCESS",
+			Changes: []jarchive.Change{{Commit: "abc123", Summary: "Update upstream", Message: "Update upstream\n\nPaper changes"}},
+
+			Checksum:          "0123456789abcdef0123456789abcdef",
+			ChecksumAlgorithm: jarchive.MD5,
+		},
+		{
+			ID:      "122",
+			Time:    time.UnixMilli(1654616406000).UTC(),
+			Result:  "FAILURE",
+			Changes: []jarchive.Change{},
+		},
+	}, builds)
 }
 
-func TestGetLatestBuild_InvalidVersion(t *testing.T) {
+func TestMirror_BuildNotFound(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer server.Close()
 
-	baseURL = server.URL + "/v2/purpur"
-
-	_, err := getLatestBuild("invalid-version")
+	config := New("1.18.2", WithBaseURL(server.URL+"/v2/purpur"))
+	config.Build = "999"
+	_, err := config.Mirror()
 
-	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+	assert.ErrorIs(t, err, jarchive.ErrBuildNotFound)
 }
@@ -0,0 +1,134 @@
+package jarchive
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ciathefed/jarchive/cache"
+)
+
+// Options holds the settings every provider understands. Providers may offer
+// further settings through their own option functions.
+type Options struct {
+	HTTPClient *http.Client
+	Cache      cache.Cache
+	CacheTTL   time.Duration
+	Offline    bool
+
+	// RetryPolicy replaces DefaultRetryPolicy when set.
+	RetryPolicy *RetryPolicy
+
+	// UserAgent replaces the default User-Agent header when set.
+	UserAgent string
+}
+
+// Option configures Options.
+type Option func(*Options)
+
+// WithHTTPClient sets the HTTP client used for all requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *Options) {
+		o.HTTPClient = client
+	}
+}
+
+// WithCache stores upstream metadata in c. Entries younger than ttl are used
+// without contacting the upstream API; older ones are revalidated.
+func WithCache(c cache.Cache, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.Cache = c
+		o.CacheTTL = ttl
+	}
+}
+
+// WithOffline answers every request from the cache alone, regardless of the
+// age of the cached entries. Anything missing from the cache fails with
+// ErrOffline.
+func WithOffline(offline bool) Option {
+	return func(o *Options) {
+		o.Offline = offline
+	}
+}
+
+// WithRetryPolicy sets how failed metadata and HEAD requests are retried.
+// Use RetryPolicy{MaxAttempts: 1} to disable retries.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *Options) {
+		o.RetryPolicy = &policy
+	}
+}
+
+// DefaultUserAgent identifies jarchive to upstream APIs and download servers
+// unless configured otherwise. Some, such as PaperMC's, reject requests
+// without one.
+const DefaultUserAgent = "jarchive (+https://github.com/ciathefed/jarchive)"
+
+// WithUserAgent sets the User-Agent header sent to upstream APIs. PaperMC asks
+// for one that names the application and a way to contact its maintainer.
+func WithUserAgent(userAgent string) Option {
+	return func(o *Options) {
+		o.UserAgent = userAgent
+	}
+}
+
+// NewOptions applies opts to an empty Options.
+func NewOptions(opts ...Option) *Options {
+	o := new(Options)
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Factory creates a provider that resolves the given Minecraft version.
+type Factory func(version string, opts ...Option) (Jarchive, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available by name. It is meant to be called from
+// the init function of the provider package, so importing the package is
+// enough to use it with New. Register panics if factory is nil or name is
+// already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("jarchive: Register factory is nil")
+	}
+	if _, dup := registry[name]; dup {
+		panic("jarchive: Register called twice for provider " + name)
+	}
+	registry[name] = factory
+}
+
+// New creates the provider registered under name.
+func New(name, version string, opts ...Option) (Jarchive, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProvider, name)
+	}
+	return factory(version, opts...)
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
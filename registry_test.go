@@ -0,0 +1,78 @@
+package jarchive
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProvider struct {
+	version string
+	options *Options
+}
+
+func (f *fakeProvider) Mirror() (string, error) {
+	return f.MirrorContext(context.Background())
+}
+
+func (f *fakeProvider) MirrorContext(ctx context.Context) (string, error) {
+	return "https://example.com/" + f.version + ".jar", nil
+}
+
+func (f *fakeProvider) Resolve() (*Artifact, error) {
+	return f.ResolveContext(context.Background())
+}
+
+func (f *fakeProvider) ResolveContext(ctx context.Context) (*Artifact, error) {
+	return &Artifact{URL: "https://example.com/" + f.version + ".jar", Version: f.version}, nil
+}
+
+func registerFake(t *testing.T, name string) {
+	t.Helper()
+	Register(name, func(version string, opts ...Option) (Jarchive, error) {
+		return &fakeProvider{version: version, options: NewOptions(opts...)}, nil
+	})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, name)
+		registryMu.Unlock()
+	})
+}
+
+func TestNew_Registered(t *testing.T) {
+	registerFake(t, "fake")
+
+	client := &http.Client{}
+	provider, err := New("fake", "1.20.4", WithHTTPClient(client))
+
+	assert.NoError(t, err)
+	fake := provider.(*fakeProvider)
+	assert.Equal(t, "1.20.4", fake.version)
+	assert.Same(t, client, fake.options.HTTPClient)
+}
+
+func TestNew_UnknownProvider(t *testing.T) {
+	_, err := New("does-not-exist", "1.20.4")
+
+	assert.ErrorIs(t, err, ErrUnknownProvider)
+	assert.Contains(t, err.Error(), `"does-not-exist"`)
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	registerFake(t, "fake")
+
+	assert.Panics(t, func() { registerFake(t, "fake") })
+}
+
+func TestRegister_NilFactory(t *testing.T) {
+	assert.Panics(t, func() { Register("nil", nil) })
+}
+
+func TestProviders(t *testing.T) {
+	registerFake(t, "zeta")
+	registerFake(t, "alpha")
+
+	assert.Equal(t, []string{"alpha", "zeta"}, Providers())
+}
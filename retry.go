@@ -0,0 +1,53 @@
+package jarchive
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how providers retry metadata and HEAD requests that
+// fail with a transient network error or a temporary upstream status.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 2 disable retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with every
+	// further attempt, up to MaxDelay.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between two attempts. A Retry-After header
+	// asking for a longer wait ends the retries instead. Zero means no cap.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is used by providers unless configured otherwise.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    10 * time.Second,
+}
+
+// Backoff returns the delay before the given retry, counting from 1. The
+// result is randomized between half and all of the exponential delay so that
+// clients failing together do not retry together.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay <= math.MaxInt64/2; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
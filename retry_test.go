@@ -0,0 +1,46 @@
+package jarchive
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{0, 0, 0},
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{5, 500 * time.Millisecond, time.Second},
+		{100, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			delay := policy.Backoff(tt.retry)
+			assert.GreaterOrEqual(t, delay, tt.min, "retry %d", tt.retry)
+			assert.LessOrEqual(t, delay, tt.max, "retry %d", tt.retry)
+		}
+	}
+}
+
+func TestRetryPolicy_BackoffUncapped(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second}
+
+	assert.Positive(t, policy.Backoff(200))
+	assert.Zero(t, RetryPolicy{}.Backoff(3))
+}
+
+func TestWithRetryPolicy(t *testing.T) {
+	assert.Nil(t, NewOptions().RetryPolicy)
+
+	o := NewOptions(WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	assert.Equal(t, &RetryPolicy{MaxAttempts: 1}, o.RetryPolicy)
+}
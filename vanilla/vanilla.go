@@ -1,47 +1,191 @@
 package vanilla
 
 import (
+	"context"
+	"crypto/sha1"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
+	"github.com/ciathefed/jarchive/internal/httputil"
+)
+
+const providerName = "vanilla"
+
+const defaultVersionManifestURL = "https://piston-meta.mojang.com/mc/game/version_manifest_v2.json"
+
+// maxConcurrentRequests limits the version JSON requests ListServerVersions
+// has in flight at once.
+const maxConcurrentRequests = 8
+
+// Version aliases resolved through the latest block of the version manifest.
+const (
+	LatestRelease  = "latest"
+	LatestSnapshot = "latest-snapshot"
 )
 
-var versionManifestURL = "https://launchermeta.mojang.com/mc/game/version_manifest.json"
+// Download selects one of the files listed in a version's downloads block.
+type Download string
+
+const (
+	DownloadServer         Download = "server"          // dedicated server jar
+	DownloadClient         Download = "client"          // client jar
+	DownloadServerMappings Download = "server_mappings" // Mojang mappings for the server jar
+	DownloadClientMappings Download = "client_mappings" // Mojang mappings for the client jar
+)
+
+// kind returns the artifact kind and the file name pattern of d.
+func (d Download) kind() (jarchive.Kind, string, bool) {
+	switch d {
+	case DownloadServer:
+		return jarchive.KindServer, "minecraft_server.%s.jar", true
+	case DownloadClient:
+		return jarchive.KindClient, "minecraft_client.%s.jar", true
+	case DownloadServerMappings:
+		return jarchive.KindMappings, "minecraft_server_mappings.%s.txt", true
+	case DownloadClientMappings:
+		return jarchive.KindMappings, "minecraft_client_mappings.%s.txt", true
+	}
+	return "", "", false
+}
+
+type manifestVersion struct {
+	ID              string    `json:"id"`
+	Type            string    `json:"type"`
+	URL             string    `json:"url"`
+	SHA1            string    `json:"sha1"`
+	ReleaseTime     time.Time `json:"releaseTime"`
+	ComplianceLevel int       `json:"complianceLevel"`
+}
+
+type versionDetails struct {
+	ReleaseTime time.Time `json:"releaseTime"`
+	JavaVersion struct {
+		Component    string `json:"component"`
+		MajorVersion int    `json:"majorVersion"`
+	} `json:"javaVersion"`
+	Downloads map[Download]struct {
+		URL  string `json:"url"`
+		SHA1 string `json:"sha1"`
+		Size int64  `json:"size"`
+	} `json:"downloads"`
+}
 
 type versionManifest struct {
-	Versions []struct {
-		ID  string `json:"id"`
-		URL string `json:"url"`
-	} `json:"versions"`
+	Latest struct {
+		Release  string `json:"release"`
+		Snapshot string `json:"snapshot"`
+	} `json:"latest"`
+	Versions []manifestVersion `json:"versions"`
 }
 
+var (
+	_ jarchive.Jarchive      = (*Config)(nil)
+	_ jarchive.VersionLister = (*Config)(nil)
+)
+
 type Config struct {
+	// Version is a version ID from the manifest, or LatestRelease or
+	// LatestSnapshot.
 	Version         string
 	versionManifest *versionManifest
+
+	client             *httputil.Client
+	versionManifestURL string
+	versionTypes       []jarchive.VersionType
+	download           Download
+}
+
+// Option configures a Config.
+type Option func(*Config)
+
+// WithHTTPClient is the provider-level form of jarchive.WithHTTPClient.
+func WithHTTPClient(client *http.Client) Option {
+	return withOptions(jarchive.WithHTTPClient(client))
+}
+
+// WithCache is the provider-level form of jarchive.WithCache.
+func WithCache(store cache.Cache, ttl time.Duration) Option {
+	return withOptions(jarchive.WithCache(store, ttl))
+}
+
+// WithOffline is the provider-level form of jarchive.WithOffline.
+func WithOffline(offline bool) Option {
+	return withOptions(jarchive.WithOffline(offline))
+}
+
+// WithRetryPolicy is the provider-level form of jarchive.WithRetryPolicy.
+func WithRetryPolicy(policy jarchive.RetryPolicy) Option {
+	return withOptions(jarchive.WithRetryPolicy(policy))
+}
+
+// WithUserAgent is the provider-level form of jarchive.WithUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return withOptions(jarchive.WithUserAgent(userAgent))
 }
 
-func New(version string) *Config {
-	return &Config{
-		Version:         version,
-		versionManifest: nil,
+// WithVersionManifestURL sets the URL of the Mojang version manifest.
+func WithVersionManifestURL(url string) Option {
+	return func(c *Config) {
+		c.versionManifestURL = url
 	}
 }
 
-func (s *Config) loadVersionManifest() error {
+// WithVersionTypes restricts listing and resolution to versions of the given
+// types. Versions of any other type are reported as not found.
+func WithVersionTypes(types ...jarchive.VersionType) Option {
+	return func(c *Config) {
+		c.versionTypes = types
+	}
+}
+
+// WithDownload selects the file to resolve. The default is DownloadServer.
+func WithDownload(download Download) Option {
+	return func(c *Config) {
+		c.download = download
+	}
+}
+
+func New(version string, opts ...Option) *Config {
+	c := &Config{
+		Version:            version,
+		versionManifest:    nil,
+		client:             httputil.NewClient(),
+		versionManifestURL: defaultVersionManifestURL,
+		download:           DownloadServer,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func init() {
+	jarchive.Register(providerName, func(version string, opts ...jarchive.Option) (jarchive.Jarchive, error) {
+		return New(version, withOptions(opts...)), nil
+	})
+}
+
+// withOptions applies the provider-independent jarchive options.
+func withOptions(opts ...jarchive.Option) Option {
+	return func(c *Config) {
+		c.client.Apply(opts...)
+	}
+}
+
+func (s *Config) loadVersionManifest(ctx context.Context) error {
 	if s.versionManifest != nil {
 		return nil
 	}
 
 	manifest := new(versionManifest)
 
-	resp, err := http.Get(versionManifestURL)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := s.client.GetBytes(ctx, s.versionManifestURL)
 	if err != nil {
 		return err
 	}
@@ -55,42 +199,214 @@ func (s *Config) loadVersionManifest() error {
 }
 
 func (c *Config) Mirror() (string, error) {
-	if err := c.loadVersionManifest(); err != nil {
-		return "", fmt.Errorf("failed to get version manifest: %v", err)
+	return c.MirrorContext(context.Background())
+}
+
+func (c *Config) MirrorContext(ctx context.Context) (string, error) {
+	artifact, err := c.ResolveContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return artifact.URL, nil
+}
+
+// Resolve returns the dedicated server jar for the configured version, or the
+// file selected with WithDownload, together with its SHA-1 and size. The
+// artifact reports the concrete version ID when Version is an alias.
+func (c *Config) Resolve() (*jarchive.Artifact, error) {
+	return c.ResolveContext(context.Background())
+}
+
+// ResolveContext is like Resolve but honors the deadline and cancellation of ctx.
+func (c *Config) ResolveContext(ctx context.Context) (*jarchive.Artifact, error) {
+	kind, fileName, ok := c.download.kind()
+	if !ok {
+		return nil, fmt.Errorf("unknown vanilla download %q", c.download)
+	}
+
+	if err := c.loadVersionManifest(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get version manifest: %w", err)
+	}
+
+	v, err := c.findVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	details, err := c.getVersionDetails(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	download := details.Downloads[c.download]
+	if download.URL == "" {
+		if c.download == DownloadServer {
+			return nil, fmt.Errorf("%w: version %s has no dedicated server download", jarchive.ErrNoServerArtifact, v.ID)
+		}
+		return nil, fmt.Errorf("%w: version %s has no %s download", jarchive.ErrNoServerArtifact, v.ID, c.download)
+	}
+
+	artifact := &jarchive.Artifact{
+		URL:         download.URL,
+		FileName:    fmt.Sprintf(fileName, v.ID),
+		Provider:    providerName,
+		Kind:        kind,
+		Version:     v.ID,
+		Size:        download.Size,
+		ReleaseTime: details.ReleaseTime,
+	}
+	if sum := download.SHA1; sum != "" {
+		artifact.Checksum = sum
+		artifact.ChecksumAlgorithm = jarchive.SHA1
+	}
+	// Some very old version files have no javaVersion block.
+	if java := details.JavaVersion; java.MajorVersion > 0 {
+		artifact.Java = &jarchive.JavaRuntime{
+			MajorVersion: java.MajorVersion,
+			Component:    java.Component,
+		}
+	}
+
+	return artifact, nil
+}
+
+// getVersionDetails fetches the version JSON of v and verifies it against the
+// SHA-1 listed in the manifest.
+func (c *Config) getVersionDetails(ctx context.Context, v *manifestVersion) (*versionDetails, error) {
+	body, err := c.client.GetBytes(ctx, v.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch version details: %w", err)
+	}
+	if v.SHA1 != "" {
+		if sum := fmt.Sprintf("%x", sha1.Sum(body)); sum != v.SHA1 {
+			return nil, fmt.Errorf("failed to verify version details: %w", &jarchive.ChecksumError{
+				Algorithm: jarchive.SHA1,
+				Expected:  v.SHA1,
+				Actual:    sum,
+			})
+		}
+	}
+
+	details := new(versionDetails)
+	if err := json.Unmarshal(body, details); err != nil {
+		return nil, fmt.Errorf("failed to decode version details: %w", err)
+	}
+
+	return details, nil
+}
+
+// findVersion looks up the configured version in the loaded manifest,
+// expanding the latest aliases and applying the version type filter.
+func (c *Config) findVersion() (*manifestVersion, error) {
+	id := c.Version
+	switch id {
+	case LatestRelease:
+		id = c.versionManifest.Latest.Release
+	case LatestSnapshot:
+		id = c.versionManifest.Latest.Snapshot
+	}
+	if id == "" {
+		return nil, fmt.Errorf("%w: manifest does not name a version for %s", jarchive.ErrVersionNotFound, c.Version)
 	}
 
+	for i, v := range c.versionManifest.Versions {
+		if v.ID != id {
+			continue
+		}
+		if !c.allowsType(jarchive.VersionType(v.Type)) {
+			return nil, fmt.Errorf("%w: %s is a %s version", jarchive.ErrVersionNotFound, id, v.Type)
+		}
+		return &c.versionManifest.Versions[i], nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", jarchive.ErrVersionNotFound, id)
+}
+
+// allowsType reports whether versions of type t pass the version type filter.
+func (c *Config) allowsType(t jarchive.VersionType) bool {
+	return len(c.versionTypes) == 0 || slices.Contains(c.versionTypes, t)
+}
+
+// ListVersions returns the versions in the Mojang version manifest that pass
+// the version type filter.
+func (c *Config) ListVersions(ctx context.Context) ([]jarchive.Version, error) {
+	if err := c.loadVersionManifest(ctx); err != nil {
+		return nil, fmt.Errorf("failed to get version manifest: %w", err)
+	}
+
+	versions := make([]jarchive.Version, 0, len(c.versionManifest.Versions))
 	for _, v := range c.versionManifest.Versions {
-		if v.ID == c.Version {
-			resp, err := http.Get(v.URL)
-			if err != nil {
-				return "", fmt.Errorf("failed to fetch version details: %v", err)
-			}
-			defer resp.Body.Close()
+		if !c.allowsType(jarchive.VersionType(v.Type)) {
+			continue
+		}
+		versions = append(versions, jarchive.Version{
+			ID:              v.ID,
+			Type:            jarchive.VersionType(v.Type),
+			ReleaseTime:     v.ReleaseTime,
+			ComplianceLevel: v.ComplianceLevel,
+		})
+	}
 
-			if resp.StatusCode > 399 {
-				return "", fmt.Errorf("failed to fetch version details: status %d", resp.StatusCode)
-			}
+	return versions, nil
+}
 
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				return "", fmt.Errorf("failed to read version details: %v", err)
-			}
+// ListServerVersions is like ListVersions but leaves out versions that do not
+// ship a dedicated server jar. The manifest does not say which versions do, so
+// this fetches the version JSON of every listed version; use WithCache to
+// avoid doing so on every call.
+func (c *Config) ListServerVersions(ctx context.Context) ([]jarchive.Version, error) {
+	versions, err := c.ListVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-			var details struct {
-				Downloads struct {
-					Server struct {
-						URL string `json:"url"`
-					} `json:"server"`
-				} `json:"downloads"`
-			}
+	byID := make(map[string]*manifestVersion, len(c.versionManifest.Versions))
+	for i := range c.versionManifest.Versions {
+		byID[c.versionManifest.Versions[i].ID] = &c.versionManifest.Versions[i]
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-			if err := json.Unmarshal(body, &details); err != nil {
-				return "", fmt.Errorf("failed to decode version details: %v", err)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		hasJar   = make([]bool, len(versions))
+		sem      = make(chan struct{}, maxConcurrentRequests)
+	)
+	for i, v := range versions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			details, err := c.getVersionDetails(ctx, byID[v.ID])
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("version %s: %w", v.ID, err)
+					cancel()
+				}
+				mu.Unlock()
+				return
 			}
+			hasJar[i] = details.Downloads[DownloadServer].URL != ""
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
 
-			return details.Downloads.Server.URL, nil
+	serverVersions := versions[:0]
+	for i, v := range versions {
+		if hasJar[i] {
+			serverVersions = append(serverVersions, v)
 		}
 	}
 
-	return "", fmt.Errorf("invalid version")
+	return serverVersions, nil
 }
@@ -1,44 +1,76 @@
 package vanilla
 
 import (
+	"context"
+	"crypto/sha1"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/ciathefed/jarchive"
+	"github.com/ciathefed/jarchive/cache"
 	"github.com/stretchr/testify/assert"
 )
 
+// noRetry keeps tests of failing upstreams fast.
+var noRetry = WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 1})
+
 func TestNew(t *testing.T) {
 	config := New("1.18.2")
 	assert.Equal(t, "1.18.2", config.Version)
 	assert.Nil(t, config.versionManifest)
+	assert.Equal(t, http.DefaultClient, config.client.HTTP)
+	assert.Nil(t, config.client.Cache)
+	assert.Equal(t, defaultVersionManifestURL, config.versionManifestURL)
+}
+
+func TestNew_WithOptions(t *testing.T) {
+	client := &http.Client{}
+	store := cache.NewMemory()
+	config := New("1.18.2", WithHTTPClient(client), WithCache(store, time.Minute), WithOffline(true), WithRetryPolicy(jarchive.RetryPolicy{MaxAttempts: 5}), WithVersionManifestURL("https://example.com/version_manifest.json"))
+	assert.Same(t, client, config.client.HTTP)
+	assert.Same(t, store, config.client.Cache)
+	assert.Equal(t, time.Minute, config.client.TTL)
+	assert.Equal(t, "https://example.com/version_manifest.json", config.versionManifestURL)
+	assert.True(t, config.client.Offline)
+	assert.Equal(t, 5, config.client.Retry.MaxAttempts)
+}
+
+func TestRegistered(t *testing.T) {
+	client := &http.Client{}
+	provider, err := jarchive.New("vanilla", "1.18.2", jarchive.WithHTTPClient(client))
+
+	assert.NoError(t, err)
+	config, ok := provider.(*Config)
+	assert.True(t, ok)
+	assert.Equal(t, "1.18.2", config.Version)
+	assert.Same(t, client, config.client.HTTP)
 }
 
 func TestLoadVersionManifest_Success(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := versionManifest{
-			Versions: []struct {
-				ID  string `json:"id"`
-				URL string `json:"url"`
-			}{
+			Versions: []manifestVersion{
 				{ID: "1.18.2", URL: "https://example.com/1.18.2.json"},
 				{ID: "1.17.1", URL: "https://example.com/1.17.1.json"},
 			},
 		}
+		response.Latest.Release = "1.18.2"
 		w.WriteHeader(http.StatusOK)
 		json.NewEncoder(w).Encode(response)
 	}))
 	defer server.Close()
 
-	versionManifestURL = server.URL
-
-	config := New("1.18.2")
-	err := config.loadVersionManifest()
+	config := New("1.18.2", WithVersionManifestURL(server.URL))
+	err := config.loadVersionManifest(context.Background())
 
 	assert.NoError(t, err)
 	assert.NotNil(t, config.versionManifest)
 	assert.Equal(t, 2, len(config.versionManifest.Versions))
+	assert.Equal(t, "1.18.2", config.versionManifest.Latest.Release)
 }
 
 func TestLoadVersionManifest_Failure(t *testing.T) {
@@ -47,22 +79,20 @@ func TestLoadVersionManifest_Failure(t *testing.T) {
 	}))
 	defer server.Close()
 
-	versionManifestURL = server.URL
-
-	config := New("1.18.2")
-	err := config.loadVersionManifest()
+	config := New("1.18.2", WithVersionManifestURL(server.URL), noRetry)
+	err := config.loadVersionManifest(context.Background())
 
 	assert.Error(t, err)
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusInternalServerError, upstreamErr.StatusCode)
 	assert.Nil(t, config.versionManifest)
 }
 
 func TestMirror_Success(t *testing.T) {
 	manifestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := versionManifest{
-			Versions: []struct {
-				ID  string `json:"id"`
-				URL string `json:"url"`
-			}{
+			Versions: []manifestVersion{
 				{ID: "1.18.2", URL: "https://example.com/1.18.2.json"},
 			},
 		}
@@ -84,13 +114,9 @@ func TestMirror_Success(t *testing.T) {
 	}))
 	defer detailsServer.Close()
 
-	versionManifestURL = manifestServer.URL
-	config := New("1.18.2")
+	config := New("1.18.2", WithVersionManifestURL(manifestServer.URL))
 	config.versionManifest = &versionManifest{
-		Versions: []struct {
-			ID  string `json:"id"`
-			URL string `json:"url"`
-		}{
+		Versions: []manifestVersion{
 			{ID: "1.18.2", URL: detailsServer.URL},
 		},
 	}
@@ -104,10 +130,7 @@ func TestMirror_Success(t *testing.T) {
 func TestMirror_InvalidVersion(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := versionManifest{
-			Versions: []struct {
-				ID  string `json:"id"`
-				URL string `json:"url"`
-			}{
+			Versions: []manifestVersion{
 				{ID: "1.18.2", URL: "https://example.com/1.18.2.json"},
 			},
 		}
@@ -116,22 +139,17 @@ func TestMirror_InvalidVersion(t *testing.T) {
 	}))
 	defer server.Close()
 
-	versionManifestURL = server.URL
-
-	config := New("invalid-version")
+	config := New("invalid-version", WithVersionManifestURL(server.URL))
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "invalid version")
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
 }
 
 func TestMirror_VersionDetailsFailure(t *testing.T) {
 	manifestServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := versionManifest{
-			Versions: []struct {
-				ID  string `json:"id"`
-				URL string `json:"url"`
-			}{
+			Versions: []manifestVersion{
 				{ID: "1.18.2", URL: "https://example.com/1.18.2.json"},
 			},
 		}
@@ -145,13 +163,9 @@ func TestMirror_VersionDetailsFailure(t *testing.T) {
 	}))
 	defer detailsServer.Close()
 
-	versionManifestURL = manifestServer.URL
-	config := New("1.18.2")
+	config := New("1.18.2", WithVersionManifestURL(manifestServer.URL), noRetry)
 	config.versionManifest = &versionManifest{
-		Versions: []struct {
-			ID  string `json:"id"`
-			URL string `json:"url"`
-		}{
+		Versions: []manifestVersion{
 			{ID: "1.18.2", URL: detailsServer.URL},
 		},
 	}
@@ -159,5 +173,369 @@ func TestMirror_VersionDetailsFailure(t *testing.T) {
 	_, err := config.Mirror()
 
 	assert.Error(t, err)
-	assert.Contains(t, err.Error(), "failed to fetch version details: status 500")
+	assert.Contains(t, err.Error(), "failed to fetch version details")
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusInternalServerError, upstreamErr.StatusCode)
+}
+
+func TestMirrorContext_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := New("1.18.2", WithVersionManifestURL(server.URL))
+	_, err := config.MirrorContext(ctx)
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResolve_Success(t *testing.T) {
+	detailsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"releaseTime": "2022-02-28T10:42:45+00:00",
+			"javaVersion": map[string]any{
+				"component":    "java-runtime-gamma",
+				"majorVersion": 17,
+			},
+			"downloads": map[string]any{
+				"server": map[string]any{
+					"url":  "https://example.com/server.jar",
+					"sha1": "c8f83c5655308435b3dcf03c06d9fe8740a77469",
+					"size": 45565290,
+				},
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer detailsServer.Close()
+
+	config := New("1.18.2")
+	config.versionManifest = &versionManifest{
+		Versions: []manifestVersion{
+			{ID: "1.18.2", URL: detailsServer.URL},
+		},
+	}
+
+	artifact, err := config.Resolve()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/server.jar", artifact.URL)
+	assert.Equal(t, "minecraft_server.1.18.2.jar", artifact.FileName)
+	assert.Equal(t, "vanilla", artifact.Provider)
+	assert.Equal(t, jarchive.KindServer, artifact.Kind)
+	assert.Equal(t, "1.18.2", artifact.Version)
+	assert.Equal(t, "c8f83c5655308435b3dcf03c06d9fe8740a77469", artifact.Checksum)
+	assert.Equal(t, jarchive.SHA1, artifact.ChecksumAlgorithm)
+	assert.Equal(t, int64(45565290), artifact.Size)
+	assert.Equal(t, time.Date(2022, 2, 28, 10, 42, 45, 0, time.UTC), artifact.ReleaseTime.UTC())
+	assert.Equal(t, &jarchive.JavaRuntime{MajorVersion: 17, Component: "java-runtime-gamma"}, artifact.Java)
+}
+
+func TestListVersions_Success(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"versions": []map[string]any{
+				{"id": "22w11a", "type": "snapshot", "url": "https://example.com/22w11a.json", "releaseTime": "2022-03-16T14:04:46+00:00"},
+				{"id": "1.18.2", "type": "release", "url": "https://example.com/1.18.2.json", "releaseTime": "2022-02-28T10:42:45+00:00"},
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	config := New("1.18.2", WithVersionManifestURL(server.URL))
+	versions, err := config.ListVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "22w11a", versions[0].ID)
+	assert.Equal(t, jarchive.VersionSnapshot, versions[0].Type)
+	assert.Equal(t, "1.18.2", versions[1].ID)
+	assert.Equal(t, jarchive.VersionRelease, versions[1].Type)
+	assert.Equal(t, time.Date(2022, 2, 28, 10, 42, 45, 0, time.UTC), versions[1].ReleaseTime.UTC())
+}
+
+func TestResolve_NoServerDownload(t *testing.T) {
+	detailsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"downloads": map[string]any{
+				"client": map[string]any{"url": "https://example.com/client.jar"},
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer detailsServer.Close()
+
+	config := New("b1.7.3")
+	config.versionManifest = &versionManifest{
+		Versions: []manifestVersion{
+			{ID: "b1.7.3", URL: detailsServer.URL},
+		},
+	}
+
+	_, err := config.Resolve()
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
+
+	url, err := config.Mirror()
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
+	assert.Empty(t, url)
+}
+
+// testManifest returns a manifest whose versions all point at detailsURL.
+func testManifest(detailsURL string) *versionManifest {
+	manifest := &versionManifest{
+		Versions: []manifestVersion{
+			{ID: "22w11a", Type: "snapshot", URL: detailsURL},
+			{ID: "1.18.2", Type: "release", URL: detailsURL},
+			{ID: "b1.7.3", Type: "old_beta", URL: detailsURL},
+		},
+	}
+	manifest.Latest.Release = "1.18.2"
+	manifest.Latest.Snapshot = "22w11a"
+	return manifest
+}
+
+func newDetailsServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"downloads": map[string]any{
+				"server": map[string]any{"url": "https://example.com/server.jar"},
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolve_LatestAliases(t *testing.T) {
+	detailsServer := newDetailsServer(t)
+
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{LatestRelease, "1.18.2"},
+		{LatestSnapshot, "22w11a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			config := New(tt.version)
+			config.versionManifest = testManifest(detailsServer.URL)
+
+			artifact, err := config.Resolve()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, artifact.Version)
+			assert.Equal(t, "minecraft_server."+tt.want+".jar", artifact.FileName)
+			assert.Nil(t, artifact.Java)
+		})
+	}
+}
+
+func TestResolve_LatestMissingFromManifest(t *testing.T) {
+	config := New(LatestRelease)
+	config.versionManifest = &versionManifest{}
+
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+}
+
+func TestResolve_VersionTypeFilter(t *testing.T) {
+	detailsServer := newDetailsServer(t)
+
+	config := New("22w11a", WithVersionTypes(jarchive.VersionRelease))
+	config.versionManifest = testManifest(detailsServer.URL)
+	_, err := config.Resolve()
+	assert.ErrorIs(t, err, jarchive.ErrVersionNotFound)
+
+	config = New("b1.7.3", WithVersionTypes(jarchive.VersionRelease, jarchive.VersionOldBeta))
+	config.versionManifest = testManifest(detailsServer.URL)
+	artifact, err := config.Resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, "b1.7.3", artifact.Version)
+}
+
+func TestListVersions_VersionTypeFilter(t *testing.T) {
+	config := New("", WithVersionTypes(jarchive.VersionSnapshot, jarchive.VersionOldBeta))
+	config.versionManifest = testManifest("https://example.com/details.json")
+
+	versions, err := config.ListVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Version{
+		{ID: "22w11a", Type: jarchive.VersionSnapshot},
+		{ID: "b1.7.3", Type: jarchive.VersionOldBeta},
+	}, versions)
+}
+
+func TestResolve_Downloads(t *testing.T) {
+	detailsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := map[string]any{
+			"downloads": map[string]any{
+				"server":          map[string]any{"url": "https://example.com/server.jar", "sha1": "s", "size": 1},
+				"client":          map[string]any{"url": "https://example.com/client.jar", "sha1": "c", "size": 2},
+				"server_mappings": map[string]any{"url": "https://example.com/server.txt", "sha1": "sm", "size": 3},
+				"client_mappings": map[string]any{"url": "https://example.com/client.txt", "sha1": "cm", "size": 4},
+			},
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer detailsServer.Close()
+
+	tests := []struct {
+		download Download
+		want     jarchive.Artifact
+	}{
+		{DownloadServer, jarchive.Artifact{URL: "https://example.com/server.jar", FileName: "minecraft_server.1.18.2.jar", Kind: jarchive.KindServer, Checksum: "s", Size: 1}},
+		{DownloadClient, jarchive.Artifact{URL: "https://example.com/client.jar", FileName: "minecraft_client.1.18.2.jar", Kind: jarchive.KindClient, Checksum: "c", Size: 2}},
+		{DownloadServerMappings, jarchive.Artifact{URL: "https://example.com/server.txt", FileName: "minecraft_server_mappings.1.18.2.txt", Kind: jarchive.KindMappings, Checksum: "sm", Size: 3}},
+		{DownloadClientMappings, jarchive.Artifact{URL: "https://example.com/client.txt", FileName: "minecraft_client_mappings.1.18.2.txt", Kind: jarchive.KindMappings, Checksum: "cm", Size: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.download), func(t *testing.T) {
+			config := New("1.18.2", WithDownload(tt.download))
+			config.versionManifest = testManifest(detailsServer.URL)
+
+			artifact, err := config.Resolve()
+
+			assert.NoError(t, err)
+			tt.want.Provider = "vanilla"
+			tt.want.Version = "1.18.2"
+			tt.want.ChecksumAlgorithm = jarchive.SHA1
+			assert.Equal(t, &tt.want, artifact)
+		})
+	}
+}
+
+func TestResolve_MissingDownload(t *testing.T) {
+	detailsServer := newDetailsServer(t)
+
+	config := New("1.18.2", WithDownload(DownloadClientMappings))
+	config.versionManifest = testManifest(detailsServer.URL)
+	_, err := config.Resolve()
+
+	assert.ErrorIs(t, err, jarchive.ErrNoServerArtifact)
+	assert.Contains(t, err.Error(), "client_mappings")
+}
+
+func TestResolve_UnknownDownload(t *testing.T) {
+	config := New("1.18.2", WithDownload("installer"))
+	config.versionManifest = testManifest("https://example.com/details.json")
+
+	_, err := config.Resolve()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"installer"`)
+}
+
+func TestResolve_VerifiesVersionDetails(t *testing.T) {
+	details := []byte(`{"downloads":{"server":{"url":"https://example.com/server.jar"}}}`)
+	detailsServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(details)
+	}))
+	defer detailsServer.Close()
+
+	config := New("1.18.2")
+	config.versionManifest = &versionManifest{
+		Versions: []manifestVersion{
+			{ID: "1.18.2", URL: detailsServer.URL, SHA1: fmt.Sprintf("%x", sha1.Sum(details))},
+		},
+	}
+	artifact, err := config.Resolve()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/server.jar", artifact.URL)
+
+	config.versionManifest.Versions[0].SHA1 = "0000000000000000000000000000000000000000"
+	_, err = config.Resolve()
+	assert.ErrorIs(t, err, jarchive.ErrChecksumMismatch)
+	var checksumErr *jarchive.ChecksumError
+	assert.ErrorAs(t, err, &checksumErr)
+	assert.Equal(t, jarchive.SHA1, checksumErr.Algorithm)
+}
+
+func TestListVersions_ComplianceLevel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{
+			"latest": {"release": "1.21", "snapshot": "1.21"},
+			"versions": [
+				{"id": "1.21", "type": "release", "url": "https://example.com/1.21.json", "time": "2024-06-13T08:32:38+00:00", "releaseTime": "2024-06-13T08:24:03+00:00", "sha1": "a01c21a62ae9ec8c4bd4ab1bcb3ebd3f1fcf7ebc", "complianceLevel": 1},
+				{"id": "1.18.2", "type": "release", "url": "https://example.com/1.18.2.json", "time": "2022-02-28T10:42:45+00:00", "releaseTime": "2022-02-28T10:42:45+00:00", "sha1": "86f9b1dd9d3bf85ef12e4e9e4c7b5c06e2f72ef8", "complianceLevel": 0}
+			]
+		}`)
+	}))
+	defer server.Close()
+
+	versions, err := New("", WithVersionManifestURL(server.URL)).ListVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, "1.21", versions[0].ID)
+	assert.Equal(t, 1, versions[0].ComplianceLevel)
+	assert.Equal(t, time.Date(2024, 6, 13, 8, 24, 3, 0, time.UTC), versions[0].ReleaseTime.UTC())
+	assert.Equal(t, "1.18.2", versions[1].ID)
+	assert.Equal(t, 0, versions[1].ComplianceLevel)
+}
+
+func TestListServerVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads := map[string]any{
+			"client": map[string]any{"url": "https://example.com/client.jar"},
+		}
+		if r.URL.Path != "/b1.7.3.json" {
+			downloads["server"] = map[string]any{"url": "https://example.com/server.jar"}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]any{"downloads": downloads})
+	}))
+	defer server.Close()
+
+	config := New("")
+	config.versionManifest = &versionManifest{
+		Versions: []manifestVersion{
+			{ID: "22w11a", Type: "snapshot", URL: server.URL + "/22w11a.json"},
+			{ID: "1.18.2", Type: "release", URL: server.URL + "/1.18.2.json"},
+			{ID: "b1.7.3", Type: "old_beta", URL: server.URL + "/b1.7.3.json"},
+		},
+	}
+
+	versions, err := config.ListServerVersions(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []jarchive.Version{
+		{ID: "22w11a", Type: jarchive.VersionSnapshot},
+		{ID: "1.18.2", Type: jarchive.VersionRelease},
+	}, versions)
+}
+
+func TestListServerVersions_DetailsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	config := New("")
+	config.versionManifest = testManifest(server.URL)
+
+	_, err := config.ListServerVersions(context.Background())
+
+	var upstreamErr *jarchive.UpstreamError
+	assert.ErrorAs(t, err, &upstreamErr)
+	assert.Equal(t, http.StatusNotFound, upstreamErr.StatusCode)
 }
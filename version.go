@@ -0,0 +1,36 @@
+package jarchive
+
+import (
+	"context"
+	"time"
+)
+
+// VersionType classifies a Minecraft version the way Mojang's manifest does.
+type VersionType string
+
+const (
+	VersionRelease  VersionType = "release"
+	VersionSnapshot VersionType = "snapshot"
+	VersionOldBeta  VersionType = "old_beta"
+	VersionOldAlpha VersionType = "old_alpha"
+)
+
+// Version is a Minecraft version offered by a provider.
+//
+// Type, ReleaseTime and ComplianceLevel are only set when the upstream API
+// reports them.
+type Version struct {
+	ID          string      `json:"id"`
+	Type        VersionType `json:"type,omitempty"`
+	ReleaseTime time.Time   `json:"release_time"`
+
+	// ComplianceLevel is Mojang's player safety compliance level: 1 for
+	// versions with the chat reporting and safety features, 0 otherwise.
+	ComplianceLevel int `json:"compliance_level,omitempty"`
+}
+
+// VersionLister is implemented by providers that can enumerate the Minecraft
+// versions they support. Versions are returned newest first.
+type VersionLister interface {
+	ListVersions(ctx context.Context) ([]Version, error)
+}